@@ -7,11 +7,17 @@ import (
 	"os/signal"
 	"subscriber-check-bot/config"
 	"subscriber-check-bot/handler"
+	"subscriber-check-bot/job"
 	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/membership"
 	"subscriber-check-bot/pkg/postgres"
 	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
 	"subscriber-check-bot/repo"
+	"sync"
 	"syscall"
+	"time"
+	_ "time/tzdata"
 )
 
 func main() {
@@ -42,35 +48,222 @@ func main() {
 	chRepo := repo.NewChannelRepo(psql)
 	msgRepo := repo.NewMessageRepo(psql)
 	userRepo := repo.NewUserRepo(psql)
+	updateRepo := repo.NewUpdateRepo(psql)
+	linkRepo := repo.NewInviteLinkRepo(psql)
+	warningRepo := repo.NewAccessWarningRepo(psql)
+	campaignRepo := repo.NewCampaignRepo(psql)
+	verifyRepo := repo.NewVerificationRepo(psql)
+	settingRepo := repo.NewSettingRepo(psql)
+	broadcastRepo := repo.NewBroadcastRepo(psql)
+	funnelRepo := repo.NewFunnelRepo(psql)
 
-	tgStore := store.NewStore()
+	// the background jobs use the database until they return, they are waited for before it is closed
+	var jobs sync.WaitGroup
+	runJob := func(run func(ctx context.Context)) {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			run(ctx)
+		}()
+	}
+
+	tgStore := store.NewPostgresStore(psql, cfg.State.TTL)
+	runJob(func(ctx context.Context) {
+		store.RunSweeper(ctx, tgStore, cfg.State.SweepInterval, log)
+	})
+
+	client := telegram.NewLimited(ctx, telegram.New(bot), telegram.Limits{
+		PerSecond:     cfg.RateLimit.PerSecond,
+		ChatInterval:  cfg.RateLimit.ChatInterval,
+		GroupInterval: cfg.RateLimit.GroupInterval,
+		MaxRetries:    cfg.RateLimit.MaxRetries,
+		MaxRetryAfter: cfg.RateLimit.MaxRetryAfter,
+	})
+
+	membershipCache := membership.NewCache(cfg.Membership.CacheTTL)
+	checker := membership.NewChecker(client, membershipCache, log,
+		cfg.Membership.Concurrency,
+		membership.FailPolicy(cfg.Membership.FailPolicy),
+	)
 
-	viewHandler := handler.ViewHandler{Log: log, ChRepo: chRepo, MsgRepo: msgRepo, Store: tgStore}
+	texts := &handler.Texts{Log: log, MsgRepo: msgRepo}
+
+	viewHandler := handler.ViewHandler{Log: log,
+		Store:        tgStore,
+		ChRepo:       chRepo,
+		MsgRepo:      msgRepo,
+		UserRepo:     userRepo,
+		CampaignRepo: campaignRepo,
+		FunnelRepo:   funnelRepo,
+		Texts:        texts,
+		BotName:      bot.Self.UserName,
+	}
 	callbackHandler := handler.CallbackHandler{Log: log,
-		Store:    tgStore,
-		ChRepo:   chRepo,
-		MsgRepo:  msgRepo,
-		UserRepo: userRepo,
+		Store:         tgStore,
+		Checker:       checker,
+		ChRepo:        chRepo,
+		MsgRepo:       msgRepo,
+		UserRepo:      userRepo,
+		LinkRepo:      linkRepo,
+		CampaignRepo:  campaignRepo,
+		VerifyRepo:    verifyRepo,
+		SettingRepo:   settingRepo,
+		BroadcastRepo: broadcastRepo,
+		FunnelRepo:    funnelRepo,
+		Texts:         texts,
+
+		BotName:     bot.Self.UserName,
+		JoinRequest: cfg.Access.JoinRequest,
+		LinkTTL:     cfg.Access.LinkTTL,
+
+		CaptchaEnabled:       cfg.Captcha.Enabled,
+		CaptchaMaxAttempts:   cfg.Captcha.MaxAttempts,
+		CaptchaBlockDuration: cfg.Captcha.BlockDuration,
+
+		Location: cfg.Location,
+	}
+
+	memberHandler := handler.MemberHandler{Log: log,
+		Cache:        membershipCache,
+		Checker:      checker,
+		Texts:        texts,
+		ChRepo:       chRepo,
+		UserRepo:     userRepo,
+		LinkRepo:     linkRepo,
+		CampaignRepo: campaignRepo,
+		JoinRequest:  cfg.Access.JoinRequest,
+	}
+
+	revoker := job.InviteLinkRevoker{Log: log,
+		Bot:      client,
+		LinkRepo: linkRepo,
+		Interval: cfg.Access.RevokeInterval,
+	}
+	runJob(revoker.Run)
+
+	broadcaster := job.Broadcaster{Log: log,
+		Bot:           client,
+		UserRepo:      userRepo,
+		MsgRepo:       msgRepo,
+		BroadcastRepo: broadcastRepo,
+		Interval:      cfg.Broadcast.PollInterval,
+		SendInterval:  cfg.Broadcast.SendInterval,
+	}
+	runJob(broadcaster.Run)
+
+	if cfg.Reverify.Enabled {
+		reverifier := job.Reverifier{Log: log,
+			Bot:          client,
+			Checker:      checker,
+			Cache:        membershipCache,
+			Texts:        texts,
+			CampaignRepo: campaignRepo,
+			LinkRepo:     linkRepo,
+			WarningRepo:  warningRepo,
+			Interval:     cfg.Reverify.Interval,
+			GracePeriod:  cfg.Reverify.GracePeriod,
+			DryRun:       cfg.Reverify.DryRun,
+		}
+		runJob(reverifier.Run)
 	}
 
-	newBot := handler.NewBot(bot, log, chRepo, msgRepo, userRepo, tgStore)
+	newBot := handler.NewBot(client, log, cfg, chRepo, msgRepo, userRepo, updateRepo, tgStore)
+
+	newBot.Use(
+		handler.Recovery(log),
+		handler.Logging(log),
+		handler.Idempotency(updateRepo, log),
+		handler.Timeout(5*time.Minute),
+		handler.UserRegistration(userRepo),
+	)
 
 	newBot.RegisterCommandView("start", viewHandler.GetStart())
-	newBot.RegisterCommandView("secret", handler.AdminMiddleware(userRepo, viewHandler.AdminGetPanel()))
-	newBot.RegisterCommandView("cancel", handler.AdminMiddleware(userRepo, viewHandler.AdminCancelCommand()))
+	newBot.RegisterCommandView("ref", viewHandler.GetReferral())
 
+	newBot.RegisterChatMember(memberHandler.ChatMemberUpdated())
+	newBot.RegisterChatJoinRequest(memberHandler.ChatJoinRequest())
+
+	// buttons without a campaign id were sent before campaigns and use the campaign of the user
 	newBot.RegisterCommandCallback("second_step", callbackHandler.SecondStep())
+	newBot.RegisterCommandCallback("second_step/{campaign:int}", callbackHandler.SecondStep())
 	newBot.RegisterCommandCallback("ready", callbackHandler.Ready())
+	newBot.RegisterCommandCallback("ready/{campaign:int}", callbackHandler.Ready())
+	newBot.RegisterCommandCallback("captcha/{campaign:int}/{answer:int}", callbackHandler.CaptchaAnswer())
+
+	admin := newBot.Group(handler.Admin(userRepo))
+
+	admin.RegisterCommandView("secret", viewHandler.AdminGetPanel())
+	admin.RegisterCommandView("cancel", viewHandler.AdminCancelCommand())
 
-	newBot.RegisterCommandCallback("admin_role_setting", handler.AdminMiddleware(userRepo, callbackHandler.AdminRoleSetting()))
-	newBot.RegisterCommandCallback("set_main_channel", handler.AdminMiddleware(userRepo, callbackHandler.AdminSetMainChannel()))
-	newBot.RegisterCommandCallback("channel_set", handler.AdminMiddleware(userRepo, callbackHandler.AdminChooseMainChannel()))
+	admin.RegisterCommandCallback("admin_role_setting", callbackHandler.AdminRoleSetting())
+	admin.RegisterCommandCallback("set_main_channel", callbackHandler.AdminSetMainChannel())
+	admin.RegisterCommandCallback("channel_set/{id:int}", callbackHandler.AdminChooseMainChannel())
 
-	newBot.RegisterCommandCallback("admin_set_role", handler.AdminMiddleware(userRepo, callbackHandler.AdminSetRole()))
-	newBot.RegisterCommandCallback("admin_delete_role", handler.AdminMiddleware(userRepo, callbackHandler.AdminDeleteRole()))
-	newBot.RegisterCommandCallback("admin_look_up", handler.AdminMiddleware(userRepo, callbackHandler.AdminLookUp()))
+	admin.RegisterCommandCallback("channels", callbackHandler.AdminChannels())
+	admin.RegisterCommandCallback("channel/{id:int}", callbackHandler.AdminChannel())
+	admin.RegisterCommandCallback("channel_up/{id:int}", callbackHandler.AdminMoveChannelUp())
+	admin.RegisterCommandCallback("channel_down/{id:int}", callbackHandler.AdminMoveChannelDown())
+	admin.RegisterCommandCallback("channel_demote/{id:int}", callbackHandler.AdminDemoteChannel())
+	admin.RegisterCommandCallback("channel_demote_confirm/{id:int}", callbackHandler.AdminConfirmDemoteChannel())
+	admin.RegisterCommandCallback("channel_show/{id:int}", callbackHandler.AdminShowChannel())
+	admin.RegisterCommandCallback("channel_link/{id:int}", callbackHandler.AdminRegenerateChannelLink())
+	admin.RegisterCommandCallback("channel_rename/{id:int}", callbackHandler.AdminRenameChannel())
+	admin.RegisterCommandCallback("channel_delete/{id:int}", callbackHandler.AdminDeleteChannel())
+	admin.RegisterCommandCallback("channel_delete_confirm/{id:int}", callbackHandler.AdminConfirmDeleteChannel())
+	admin.RegisterStateView(store.ChannelRenameStore{}.Kind(), callbackHandler.AdminChannelName())
+
+	admin.RegisterCommandCallback("campaigns", callbackHandler.AdminCampaigns())
+	admin.RegisterCommandCallback("campaign/{id:int}", callbackHandler.AdminCampaign())
+	admin.RegisterCommandCallback("campaign_create", callbackHandler.AdminCreateCampaign())
+	admin.RegisterCommandCallback("campaign_target/{channel:int}", callbackHandler.AdminCampaignTarget())
+	admin.RegisterCommandCallback("campaign_channels/{id:int}", callbackHandler.AdminCampaignChannels())
+	admin.RegisterCommandCallback("campaign_toggle/{id:int}/{channel:int}", callbackHandler.AdminToggleCampaignChannel())
+	admin.RegisterCommandCallback("campaign_delete/{id:int}", callbackHandler.AdminDeleteCampaign())
+	admin.RegisterCommandCallback("campaign_delete_confirm/{id:int}", callbackHandler.AdminConfirmDeleteCampaign())
+	admin.RegisterStateView(store.CampaignStore{}.Kind(), callbackHandler.AdminCampaignName())
+
+	admin.RegisterCommandCallback("channel_rules", callbackHandler.AdminChannelRules())
+	admin.RegisterCommandCallback("channel_rule/{id:int}", callbackHandler.AdminChannelRule())
+	admin.RegisterCommandCallback("channel_rule_required/{id:int}", callbackHandler.AdminToggleChannelRequired())
+	admin.RegisterCommandCallback("channel_rule_restricted/{id:int}", callbackHandler.AdminToggleChannelRestricted())
+	admin.RegisterCommandCallback("channel_rule_period/{id:int}", callbackHandler.AdminChannelPeriod())
+	admin.RegisterCommandCallback("channel_rule_order/{id:int}", callbackHandler.AdminChannelOrder())
+	admin.RegisterStateView(store.ChannelRuleStore{}.Kind(), callbackHandler.AdminChannelRuleValue())
+
+	admin.RegisterCommandCallback("stats", callbackHandler.AdminStats())
+	admin.RegisterCommandCallback("top_referrers", callbackHandler.AdminTopReferrers())
+	admin.RegisterCommandCallback("verifications", callbackHandler.AdminVerifications())
+	admin.RegisterCommandCallback("verification_user", callbackHandler.AdminVerificationUser())
+	admin.RegisterStateView(store.VerificationStore{}.Kind(), callbackHandler.AdminVerificationInput())
+	admin.RegisterCommandCallback("captcha", callbackHandler.AdminCaptcha())
+	admin.RegisterCommandCallback("captcha_toggle", callbackHandler.AdminToggleCaptcha())
+
+	admin.RegisterCommandCallback("broadcast", callbackHandler.AdminBroadcast())
+	admin.RegisterCommandCallback("broadcast_send/{id:int}", callbackHandler.AdminBroadcastSend())
+	admin.RegisterCommandCallback("broadcast_discard/{id:int}", callbackHandler.AdminBroadcastDiscard())
+	admin.RegisterCommandCallback("broadcast_cancel/{id:int}", callbackHandler.AdminBroadcastCancel())
+	admin.RegisterStateView(store.BroadcastStore{}.Kind(), callbackHandler.AdminBroadcastInput())
+
+	admin.RegisterCommandCallback("texts", callbackHandler.AdminTexts())
+	admin.RegisterCommandCallback("text/{key}", callbackHandler.AdminText())
+	admin.RegisterCommandCallback("text_edit/{key}", callbackHandler.AdminTextEdit())
+	admin.RegisterCommandCallback("text_media/{key}", callbackHandler.AdminTextMedia())
+	admin.RegisterCommandCallback("text_button/{key}", callbackHandler.AdminTextButton())
+	admin.RegisterCommandCallback("text_preview/{key}", callbackHandler.AdminTextPreview())
+	admin.RegisterCommandCallback("text_reset/{key}", callbackHandler.AdminTextReset())
+	admin.RegisterStateView(store.TextStore{}.Kind(), callbackHandler.AdminTextInput())
+
+	admin.RegisterCommandCallback("admin_set_role", callbackHandler.AdminSetRole())
+	admin.RegisterCommandCallback("admin_delete_role", callbackHandler.AdminDeleteRole())
+	admin.RegisterCommandCallback("admin_look_up", callbackHandler.AdminLookUp())
+	admin.RegisterStateView(store.AdminStore{}.Kind(), callbackHandler.AdminRoleInput())
 
 	if err := newBot.Run(ctx); err != nil {
-		log.Fatal("failed to run tgbot: %v", err)
+		log.Error("failed to run tgbot: %v", err)
 	}
+	cancel()
+	jobs.Wait()
+
+	log.Info("bot stopped")
+	_ = log.Sync()
 }
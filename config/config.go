@@ -1,14 +1,40 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"github.com/joho/godotenv"
 	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// FailPolicyFail and FailPolicyPass are the values of MEMBERSHIP_FAIL_POLICY, see membership.FailPolicy.
+const (
+	FailPolicyFail = "fail"
+	FailPolicyPass = "pass"
 )
 
 type (
 	Config struct {
-		Postgres Postgres `json:"postgres"`
-		Telegram Telegram `json:"telegram"`
+		Postgres   Postgres   `json:"postgres"`
+		Telegram   Telegram   `json:"telegram"`
+		Worker     Worker     `json:"worker"`
+		State      State      `json:"state"`
+		RateLimit  RateLimit  `json:"rate_limit"`
+		Membership Membership `json:"membership"`
+		Access     Access     `json:"access"`
+		Reverify   Reverify   `json:"reverify"`
+		Captcha    Captcha    `json:"captcha"`
+		Broadcast  Broadcast  `json:"broadcast"`
+
+		// Location is the time zone admins enter dates in.
+		Location *time.Location `json:"-"`
 	}
 
 	Postgres struct {
@@ -16,7 +42,65 @@ type (
 	}
 
 	Telegram struct {
-		Token string `json:"token"`
+		Token   string  `json:"token"`
+		Mode    string  `json:"mode"`
+		Webhook Webhook `json:"webhook"`
+	}
+
+	Webhook struct {
+		URL         string `json:"url"`
+		ListenAddr  string `json:"listen_addr"`
+		SecretToken string `json:"secret_token"`
+	}
+
+	Worker struct {
+		Count       int           `json:"count"`
+		QueueSize   int           `json:"queue_size"`
+		GracePeriod time.Duration `json:"grace_period"`
+	}
+
+	RateLimit struct {
+		PerSecond     int           `json:"per_second"`
+		ChatInterval  time.Duration `json:"chat_interval"`
+		GroupInterval time.Duration `json:"group_interval"`
+		MaxRetries    int           `json:"max_retries"`
+		MaxRetryAfter time.Duration `json:"max_retry_after"`
+	}
+
+	Membership struct {
+		CacheTTL    time.Duration `json:"cache_ttl"`
+		Concurrency int           `json:"concurrency"`
+		FailPolicy  string        `json:"fail_policy"`
+	}
+
+	Access struct {
+		JoinRequest    bool          `json:"join_request"`
+		LinkTTL        time.Duration `json:"link_ttl"`
+		RevokeInterval time.Duration `json:"revoke_interval"`
+	}
+
+	Reverify struct {
+		Enabled     bool          `json:"enabled"`
+		Interval    time.Duration `json:"interval"`
+		GracePeriod time.Duration `json:"grace_period"`
+		DryRun      bool          `json:"dry_run"`
+	}
+
+	// Captcha.Enabled is the initial state of the challenge, admins switch it at runtime.
+	Captcha struct {
+		Enabled       bool          `json:"enabled"`
+		MaxAttempts   int           `json:"max_attempts"`
+		BlockDuration time.Duration `json:"block_duration"`
+	}
+
+	Broadcast struct {
+		PollInterval time.Duration `json:"poll_interval"`
+		SendInterval time.Duration `json:"send_interval"`
+	}
+
+	State struct {
+		TTL           time.Duration `json:"ttl"`
+		SweepInterval time.Duration `json:"sweep_interval"`
 	}
 )
 
@@ -26,14 +110,204 @@ func New() (*Config, error) {
 		return nil, err
 	}
 
+	return load()
+}
+
+// load builds the config from the environment and validates it.
+func load() (*Config, error) {
+	location, err := time.LoadLocation(getEnv("TIMEZONE", "Europe/Moscow"))
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envReader{}
+
 	config := &Config{
 		Postgres: Postgres{
 			URL: os.Getenv("POSTGRES_URL"),
 		},
 		Telegram: Telegram{
 			Token: os.Getenv("TOKEN_TG"),
+			Mode:  getEnv("UPDATE_MODE", ModePolling),
+			Webhook: Webhook{
+				URL:         os.Getenv("WEBHOOK_URL"),
+				ListenAddr:  getEnv("WEBHOOK_LISTEN_ADDR", ":8080"),
+				SecretToken: os.Getenv("WEBHOOK_SECRET_TOKEN"),
+			},
+		},
+		Worker: Worker{
+			Count:       env.getInt("WORKER_COUNT", 8),
+			QueueSize:   env.getInt("WORKER_QUEUE_SIZE", 64),
+			GracePeriod: env.getDuration("SHUTDOWN_GRACE_PERIOD", 30*time.Second),
 		},
+		State: State{
+			TTL:           env.getDuration("STATE_TTL", 30*time.Minute),
+			SweepInterval: env.getDuration("STATE_SWEEP_INTERVAL", time.Minute),
+		},
+		Membership: Membership{
+			CacheTTL:    env.getDuration("MEMBERSHIP_CACHE_TTL", 10*time.Minute),
+			Concurrency: env.getInt("MEMBERSHIP_CONCURRENCY", 5),
+			FailPolicy:  getEnv("MEMBERSHIP_FAIL_POLICY", FailPolicyFail),
+		},
+		Access: Access{
+			JoinRequest:    env.getBool("JOIN_REQUEST_MODE", false),
+			LinkTTL:        env.getDuration("INVITE_LINK_TTL", 24*time.Hour),
+			RevokeInterval: env.getDuration("INVITE_LINK_REVOKE_INTERVAL", 10*time.Minute),
+		},
+		Reverify: Reverify{
+			Enabled:     env.getBool("REVERIFY_ENABLED", false),
+			Interval:    env.getDuration("REVERIFY_INTERVAL", 6*time.Hour),
+			GracePeriod: env.getDuration("REVERIFY_GRACE_PERIOD", 24*time.Hour),
+			DryRun:      env.getBool("REVERIFY_DRY_RUN", false),
+		},
+		Captcha: Captcha{
+			Enabled:       env.getBool("CAPTCHA_ENABLED", false),
+			MaxAttempts:   env.getInt("CAPTCHA_MAX_ATTEMPTS", 3),
+			BlockDuration: env.getDuration("CAPTCHA_BLOCK_DURATION", time.Hour),
+		},
+		Broadcast: Broadcast{
+			PollInterval: env.getDuration("BROADCAST_POLL_INTERVAL", 5*time.Second),
+			SendInterval: env.getDuration("BROADCAST_SEND_INTERVAL", 50*time.Millisecond),
+		},
+		RateLimit: RateLimit{
+			PerSecond:     env.getInt("RATE_LIMIT_PER_SECOND", 30),
+			ChatInterval:  env.getDuration("RATE_LIMIT_CHAT_INTERVAL", time.Second),
+			GroupInterval: env.getDuration("RATE_LIMIT_GROUP_INTERVAL", 3*time.Second),
+			MaxRetries:    env.getInt("RATE_LIMIT_MAX_RETRIES", 3),
+			MaxRetryAfter: env.getDuration("RATE_LIMIT_MAX_RETRY_AFTER", 30*time.Second),
+		},
+		Location: location,
+	}
+
+	if err := errors.Join(env.errs...); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
+
+// validate rejects settings the bot can't run safely with.
+func (c *Config) validate() error {
+	switch c.Telegram.Mode {
+	case ModePolling:
+	case ModeWebhook:
+		if c.Telegram.Webhook.URL == "" {
+			return errors.New("WEBHOOK_URL is required in webhook mode")
+		}
+		// without the secret anyone could post forged updates, including admin callbacks
+		if c.Telegram.Webhook.SecretToken == "" {
+			return errors.New("WEBHOOK_SECRET_TOKEN is required in webhook mode")
+		}
+	default:
+		return fmt.Errorf("UPDATE_MODE %q: want %s or %s", c.Telegram.Mode, ModePolling, ModeWebhook)
+	}
+
+	switch c.Membership.FailPolicy {
+	case FailPolicyFail, FailPolicyPass:
+	default:
+		return fmt.Errorf("MEMBERSHIP_FAIL_POLICY %q: want %s or %s", c.Membership.FailPolicy, FailPolicyFail, FailPolicyPass)
+	}
+
+	// 0 is meaningful for some settings: an unbuffered queue, no retries, no pause between broadcast messages
+	var errs []error
+	atLeast := func(key string, value, min int) {
+		if value < min {
+			errs = append(errs, fmt.Errorf("%s: %d is less than %d", key, value, min))
+		}
+	}
+	positive := func(key string, value time.Duration) {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s: %s must be positive", key, value))
+		}
+	}
+	notNegative := func(key string, value time.Duration) {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("%s: %s is negative", key, value))
+		}
+	}
+
+	atLeast("WORKER_COUNT", c.Worker.Count, 1)
+	atLeast("WORKER_QUEUE_SIZE", c.Worker.QueueSize, 0)
+	positive("SHUTDOWN_GRACE_PERIOD", c.Worker.GracePeriod)
+	positive("STATE_TTL", c.State.TTL)
+	positive("STATE_SWEEP_INTERVAL", c.State.SweepInterval)
+	notNegative("MEMBERSHIP_CACHE_TTL", c.Membership.CacheTTL)
+	atLeast("MEMBERSHIP_CONCURRENCY", c.Membership.Concurrency, 1)
+	positive("INVITE_LINK_TTL", c.Access.LinkTTL)
+	positive("INVITE_LINK_REVOKE_INTERVAL", c.Access.RevokeInterval)
+	positive("REVERIFY_INTERVAL", c.Reverify.Interval)
+	notNegative("REVERIFY_GRACE_PERIOD", c.Reverify.GracePeriod)
+	atLeast("CAPTCHA_MAX_ATTEMPTS", c.Captcha.MaxAttempts, 1)
+	positive("CAPTCHA_BLOCK_DURATION", c.Captcha.BlockDuration)
+	positive("BROADCAST_POLL_INTERVAL", c.Broadcast.PollInterval)
+	notNegative("BROADCAST_SEND_INTERVAL", c.Broadcast.SendInterval)
+	atLeast("RATE_LIMIT_PER_SECOND", c.RateLimit.PerSecond, 1)
+	notNegative("RATE_LIMIT_CHAT_INTERVAL", c.RateLimit.ChatInterval)
+	notNegative("RATE_LIMIT_GROUP_INTERVAL", c.RateLimit.GroupInterval)
+	atLeast("RATE_LIMIT_MAX_RETRIES", c.RateLimit.MaxRetries, 0)
+	notNegative("RATE_LIMIT_MAX_RETRY_AFTER", c.RateLimit.MaxRetryAfter)
+
+	return errors.Join(errs...)
+}
+
+func getEnv(key string, def string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	return value
+}
+
+// envReader reads typed settings, a value that doesn't parse is an error instead of the default.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) getInt(key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %w", key, err))
+		return def
+	}
+
+	return value
+}
+
+func (e *envReader) getBool(key string, def bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %w", key, err))
+		return def
+	}
+
+	return value
+}
+
+func (e *envReader) getDuration(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %w", key, err))
+		return def
+	}
+
+	return value
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+		check   func(t *testing.T, cfg *Config)
+	}{
+		{name: "defaults", check: func(t *testing.T, cfg *Config) {
+			if cfg.Telegram.Mode != ModePolling || cfg.Membership.FailPolicy != FailPolicyFail {
+				t.Errorf("mode = %q, fail policy = %q", cfg.Telegram.Mode, cfg.Membership.FailPolicy)
+			}
+		}},
+		{name: "webhook", env: map[string]string{
+			"UPDATE_MODE": ModeWebhook, "WEBHOOK_URL": "https://bot.example.com/hook", "WEBHOOK_SECRET_TOKEN": "s3cret",
+		}},
+		{name: "webhook without secret", env: map[string]string{
+			"UPDATE_MODE": ModeWebhook, "WEBHOOK_URL": "https://bot.example.com/hook",
+		}, wantErr: true},
+		{name: "webhook without url", env: map[string]string{
+			"UPDATE_MODE": ModeWebhook, "WEBHOOK_SECRET_TOKEN": "s3cret",
+		}, wantErr: true},
+		{name: "unknown mode", env: map[string]string{"UPDATE_MODE": "push"}, wantErr: true},
+		{name: "pass policy", env: map[string]string{"MEMBERSHIP_FAIL_POLICY": FailPolicyPass}},
+		{name: "misspelled policy", env: map[string]string{"MEMBERSHIP_FAIL_POLICY": "pas"}, wantErr: true},
+		{name: "zero where zero is meaningful", env: map[string]string{
+			"WORKER_QUEUE_SIZE": "0", "RATE_LIMIT_MAX_RETRIES": "0", "BROADCAST_SEND_INTERVAL": "0s",
+		}, check: func(t *testing.T, cfg *Config) {
+			if cfg.Worker.QueueSize != 0 || cfg.RateLimit.MaxRetries != 0 || cfg.Broadcast.SendInterval != 0 {
+				t.Errorf("queue = %d, retries = %d, send interval = %s, want zeros",
+					cfg.Worker.QueueSize, cfg.RateLimit.MaxRetries, cfg.Broadcast.SendInterval)
+			}
+		}},
+		{name: "zero workers", env: map[string]string{"WORKER_COUNT": "0"}, wantErr: true},
+		{name: "negative duration", env: map[string]string{"STATE_TTL": "-1m"}, wantErr: true},
+		{name: "unparsable int", env: map[string]string{"WORKER_COUNT": "eight"}, wantErr: true},
+		{name: "unparsable bool", env: map[string]string{"CAPTCHA_ENABLED": "yes please"}, wantErr: true},
+		{name: "unparsable duration", env: map[string]string{"INVITE_LINK_TTL": "24"}, wantErr: true},
+		{name: "time zone", env: map[string]string{"TIMEZONE": "Asia/Yekaterinburg"}, check: func(t *testing.T, cfg *Config) {
+			if _, offset := time.Date(2026, 1, 1, 0, 0, 0, 0, cfg.Location).Zone(); offset != 5*60*60 {
+				t.Errorf("offset = %d, want +5h", offset)
+			}
+		}},
+		{name: "unknown time zone", env: map[string]string{"TIMEZONE": "Mars/Olympus"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load error = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && tt.check != nil {
+				tt.check(t, cfg)
+			}
+		})
+	}
+}
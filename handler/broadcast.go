@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"net/url"
+	"strings"
+	"subscriber-check-bot/job"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
+)
+
+// AdminBroadcast starts composing a broadcast: the message, then the optional button, then the preview.
+func (c *CallbackHandler) AdminBroadcast() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		text := "Отправьте сообщение для рассылки. Можно прикрепить фото, видео, GIF или файл, " +
+			"текст тогда станет подписью.\nДля отмены команды отправьте /cancel"
+
+		msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		if err := c.Store.Set(ctx, store.BroadcastStore{
+			Step: store.BroadcastStepMessage,
+		}, update.CallbackQuery.Message.Chat.ID); err != nil {
+			c.Log.Error("Store.Set: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminBroadcastInput is the state view receiving the message and the button of the broadcast.
+func (c *CallbackHandler) AdminBroadcastInput() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		state, ok := State(ctx).(store.BroadcastStore)
+		if !ok {
+			return nil
+		}
+
+		switch state.Step {
+		case store.BroadcastStepMessage:
+			return c.broadcastMessage(ctx, bot, update)
+		case store.BroadcastStepButton:
+			return c.broadcastButton(ctx, bot, update, state.MessageID)
+		}
+
+		return nil
+	}
+}
+
+func (c *CallbackHandler) broadcastMessage(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+	message := messageFromUpdate(update.Message)
+	if message.Message == nil && message.FileID == nil {
+		HandleError(bot, update, "Такое сообщение нельзя разослать, отправьте текст, фото, видео, GIF или файл")
+		return nil
+	}
+
+	if err := c.MsgRepo.Create(ctx, message); err != nil {
+		c.Log.Error("broadcastMessage: MsgRepo.Create: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil
+	}
+
+	if err := c.Store.Set(ctx, store.BroadcastStore{
+		Step:      store.BroadcastStepButton,
+		MessageID: message.ID,
+	}, update.Message.Chat.ID); err != nil {
+		c.Log.Error("Store.Set: %v", err)
+		return err
+	}
+
+	text := "Отправьте кнопку-ссылку в формате:\nТекст кнопки | https://example.com\nили -, чтобы разослать без кнопки"
+	if _, err := bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, text)); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (c *CallbackHandler) broadcastButton(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, messageID int) error {
+	input := strings.TrimSpace(update.Message.Text)
+
+	if input != "-" {
+		buttonText, buttonURL, ok := parseButton(input)
+		if !ok {
+			HandleError(bot, update, "Неверный формат кнопки, отправьте например:\nПодробнее | https://example.com")
+			return nil
+		}
+
+		if err := c.MsgRepo.UpdateButtonByID(ctx, &buttonText, &buttonURL, messageID); err != nil {
+			c.Log.Error("broadcastButton: MsgRepo.UpdateButtonByID: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+	}
+
+	if err := c.Store.Delete(ctx, update.Message.Chat.ID); err != nil {
+		c.Log.Error("broadcastButton: Store.Delete: %v", err)
+	}
+
+	message, err := c.MsgRepo.GetByID(ctx, messageID)
+	if err != nil {
+		c.Log.Error("broadcastButton: MsgRepo.GetByID: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil
+	}
+
+	users, err := c.UserRepo.CountUsers(ctx)
+	if err != nil {
+		c.Log.Error("broadcastButton: UserRepo.CountUsers: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil
+	}
+
+	if _, err := bot.Send(telegram.NewMessage(update.Message.Chat.ID, message)); err != nil {
+		c.Log.Error("broadcastButton: preview: %v", err)
+		HandleError(bot, update, "Не удалось показать сообщение, проверьте текст и кнопку и начните заново")
+		return nil
+	}
+
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+		fmt.Sprintf("Так будет выглядеть рассылка. Получателей: %d", users))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Отправить", fmt.Sprintf("broadcast_send/%d", messageID)),
+			tgbotapi.NewInlineKeyboardButtonData("Отменить", fmt.Sprintf("broadcast_discard/%d", messageID)),
+		),
+	)
+	if _, err := bot.Send(msg); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// AdminBroadcastSend queues the previewed broadcast, the message with the pressed button shows its progress.
+func (c *CallbackHandler) AdminBroadcastSend() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		broadcast := &model.Broadcast{
+			MessageID:         CallbackParams(ctx).Int("id"),
+			ChatID:            update.CallbackQuery.Message.Chat.ID,
+			ProgressMessageID: update.CallbackQuery.Message.MessageID,
+		}
+
+		if err := c.BroadcastRepo.Create(ctx, broadcast); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && (pgErr.Code == "23505" || pgErr.Code == "23503") {
+				HandleError(bot, update, "Эта рассылка уже отправлена или отменена")
+				return nil
+			}
+			c.Log.Error("AdminBroadcastSend: BroadcastRepo.Create: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+		c.Log.Info("broadcast %d queued by %d", broadcast.ID, update.CallbackQuery.From.ID)
+
+		msg := tgbotapi.NewEditMessageTextAndMarkup(broadcast.ChatID, broadcast.ProgressMessageID, job.BroadcastText(broadcast),
+			tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Остановить", fmt.Sprintf("broadcast_cancel/%d", broadcast.ID)),
+			)))
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (c *CallbackHandler) AdminBroadcastDiscard() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		if err := c.MsgRepo.DeleteByID(ctx, CallbackParams(ctx).Int("id")); err != nil {
+			c.Log.Error("AdminBroadcastDiscard: MsgRepo.DeleteByID: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		msg := tgbotapi.NewEditMessageText(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID,
+			"Рассылка отменена")
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminBroadcastCancel stops the broadcast. A running one is stopped by the job, which then shows the summary.
+func (c *CallbackHandler) AdminBroadcastCancel() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		id := CallbackParams(ctx).Int("id")
+
+		broadcast, err := c.BroadcastRepo.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				HandleError(bot, update, "Рассылка не найдена")
+				return nil
+			}
+			c.Log.Error("AdminBroadcastCancel: BroadcastRepo.GetByID: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		cancelled, err := c.BroadcastRepo.Cancel(ctx, id)
+		if err != nil {
+			c.Log.Error("AdminBroadcastCancel: BroadcastRepo.Cancel: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		text := "Останавливаем рассылку"
+		if !cancelled {
+			text = "Рассылка уже завершена"
+		}
+		if _, err := bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, text)); err != nil {
+			c.Log.Error("failed to answer callback: %v", err)
+		}
+
+		if cancelled && broadcast.Status == model.BroadcastPending {
+			broadcast.Status = model.BroadcastCancelled
+			msg := tgbotapi.NewEditMessageText(broadcast.ChatID, broadcast.ProgressMessageID, job.BroadcastText(broadcast))
+			if _, err := bot.Send(msg); err != nil {
+				c.Log.Error("failed to send message: %v", err)
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// messageFromUpdate takes the text or the caption and the largest photo, video, GIF or file of msg.
+func messageFromUpdate(msg *tgbotapi.Message) *model.Message {
+	message := &model.Message{}
+
+	text := msg.Text
+	if text == "" {
+		text = msg.Caption
+	}
+	if text != "" {
+		message.Message = &text
+	}
+
+	var fileID string
+	var fileType model.FileType
+
+	switch {
+	case len(msg.Photo) > 0:
+		fileID, fileType = msg.Photo[len(msg.Photo)-1].FileID, model.FileTypePhoto
+	case msg.Video != nil:
+		fileID, fileType = msg.Video.FileID, model.FileTypeVideo
+	case msg.Animation != nil:
+		fileID, fileType = msg.Animation.FileID, model.FileTypeAnimation
+	case msg.Document != nil:
+		fileID, fileType = msg.Document.FileID, model.FileTypeDocument
+	default:
+		return message
+	}
+
+	message.FileID, message.FileType = &fileID, &fileType
+	return message
+}
+
+// parseButton parses "text | https://url".
+func parseButton(input string) (string, string, bool) {
+	text, link, ok := strings.Cut(input, "|")
+	if !ok {
+		return "", "", false
+	}
+
+	text, link = strings.TrimSpace(text), strings.TrimSpace(link)
+	if text == "" {
+		return "", "", false
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", false
+	}
+
+	return text, link, true
+}
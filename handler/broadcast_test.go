@@ -0,0 +1,33 @@
+package handler
+
+import "testing"
+
+func TestParseButton(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantText string
+		wantLink string
+		wantOk   bool
+	}{
+		{"text and link", "Канал | https://t.me/channel", "Канал", "https://t.me/channel", true},
+		{"no spaces", "Сайт|http://example.com/page?a=1", "Сайт", "http://example.com/page?a=1", true},
+		{"pipe in the link", "Поиск | https://example.com/?q=a|b", "Поиск", "https://example.com/?q=a|b", true},
+		{"no separator", "Канал https://t.me/channel", "", "", false},
+		{"empty text", " | https://t.me/channel", "", "", false},
+		{"empty link", "Канал | ", "", "", false},
+		{"no scheme", "Канал | t.me/channel", "", "", false},
+		{"other scheme", "Канал | tg://resolve?domain=channel", "", "", false},
+		{"no host", "Канал | https://", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, link, ok := parseButton(tt.input)
+			if text != tt.wantText || link != tt.wantLink || ok != tt.wantOk {
+				t.Fatalf("parseButton(%q) = %q, %q, %t, want %q, %q, %t",
+					tt.input, text, link, ok, tt.wantText, tt.wantLink, tt.wantOk)
+			}
+		})
+	}
+}
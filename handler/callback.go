@@ -3,21 +3,50 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strings"
 	"subscriber-check-bot/model"
 	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/membership"
 	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
 	"subscriber-check-bot/repo"
+	"time"
 )
 
 type CallbackHandler struct {
-	Log   *logger.Logger
-	Store *store.Store
-
-	ChRepo   repo.ChannelRepo
-	MsgRepo  repo.MessageRepo
-	UserRepo repo.UserRepo
+	Log     *logger.Logger
+	Store   store.Store
+	Checker *membership.Checker
+	Texts   *Texts
+
+	ChRepo        repo.ChannelRepo
+	MsgRepo       repo.MessageRepo
+	UserRepo      repo.UserRepo
+	LinkRepo      repo.InviteLinkRepo
+	CampaignRepo  repo.CampaignRepo
+	VerifyRepo    repo.VerificationRepo
+	SettingRepo   repo.SettingRepo
+	BroadcastRepo repo.BroadcastRepo
+	FunnelRepo    repo.FunnelRepo
+
+	// BotName is the username of the bot, campaign deep-links point to it.
+	BotName string
+
+	// JoinRequest makes Ready issue links that create join requests instead of single-use links.
+	JoinRequest bool
+	LinkTTL     time.Duration
+
+	// CaptchaEnabled is used until an admin switches the captcha in the panel.
+	CaptchaEnabled       bool
+	CaptchaMaxAttempts   int
+	CaptchaBlockDuration time.Duration
+
+	// Location is the time zone of the dates admins enter and see.
+	Location *time.Location
 }
 
 func createChannelMarkup(channel []model.Channel, command string) (*tgbotapi.InlineKeyboardMarkup, error) {
@@ -30,9 +59,13 @@ func createChannelMarkup(channel []model.Channel, command string) (*tgbotapi.Inl
 		var btn tgbotapi.InlineKeyboardButton
 
 		if command == "user" {
-			btn = tgbotapi.NewInlineKeyboardButtonURL(el.Name, el.URL)
+			name := el.Name
+			if !el.RequiredAt(time.Now()) {
+				name += " (по желанию)"
+			}
+			btn = tgbotapi.NewInlineKeyboardButtonURL(name, el.URL)
 		} else {
-			btn = tgbotapi.NewInlineKeyboardButtonData(el.Name, fmt.Sprintf("channel_%s_%d", command, el.ID))
+			btn = tgbotapi.NewInlineKeyboardButtonData(el.Name, fmt.Sprintf("channel_%s/%d", command, el.ID))
 		}
 
 		row = append(row, btn)
@@ -50,21 +83,27 @@ func createChannelMarkup(channel []model.Channel, command string) (*tgbotapi.Inl
 }
 
 func (c *CallbackHandler) SecondStep() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
-
-		channels, err := c.ChRepo.GetByStatus(ctx, model.ChannelStatusSecondary)
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		campaign, err := c.campaign(ctx, update.CallbackQuery.From.ID)
 		if err != nil {
-			c.Log.Error("SecondStep: ChRepo.GetByStatus: %v", err)
+			if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errForeignCampaign) {
+				HandleError(bot, update, "Кампания завершена, начните заново с /start")
+				return nil
+			}
+			c.Log.Error("SecondStep: campaign: %v", err)
 			HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
 			return nil
 		}
 
-		if channels == nil {
-			c.Log.Error("SecondStep: channels == nil")
+		channels := campaign.Channels
+		if campaign.Target == nil {
+			c.Log.Error("SecondStep: campaign %d has no target channel", campaign.ID)
 			HandleError(bot, update, "Каналов не найдено")
 			return nil
 		}
 
+		recordFunnel(ctx, c.Log, c.FunnelRepo, update.CallbackQuery.From.ID, model.FunnelSecondStep, campaign.ID)
+
 		markup, err := createChannelMarkup(channels, "user")
 		if err != nil {
 			c.Log.Error("SecondStep: createChannelMarkup: %v", err)
@@ -72,21 +111,12 @@ func (c *CallbackHandler) SecondStep() ViewFunc {
 			return nil
 		}
 
-		text := "Пожалуйста, подпишитесь на каналы"
-		msg := tgbotapi.NewEditMessageText(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID, text)
-		msg.ReplyMarkup = markup
-
-		if _, err := bot.Send(msg); err != nil {
+		if err := editOrSend(bot, update, c.Texts.Get(ctx, TextSubscribe), markup.InlineKeyboard...); err != nil {
 			c.Log.Error("failed to send message: %v", err)
 			return err
 		}
 
-		textSec := "После подписки на все каналы нажмите на кнопку - ГОТОВО"
-		msgSec := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, textSec)
-		msgSec.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("ГОТОВО", "ready")))
-
+		msgSec := telegram.NewMessage(update.CallbackQuery.Message.Chat.ID, c.Texts.Get(ctx, TextReadyHint), readyRow(campaign.ID))
 		if _, err := bot.Send(msgSec); err != nil {
 			c.Log.Error("failed to send message: %v", err)
 			return err
@@ -97,95 +127,156 @@ func (c *CallbackHandler) SecondStep() ViewFunc {
 }
 
 func (c *CallbackHandler) Ready() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
-		channels, err := c.ChRepo.GetByStatus(ctx, model.ChannelStatusSecondary)
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		campaign, err := c.campaign(ctx, update.CallbackQuery.From.ID)
 		if err != nil {
-			c.Log.Error("Ready: ChRepo.GetByStatus: %v", err)
+			if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errForeignCampaign) {
+				HandleError(bot, update, "Кампания завершена, начните заново с /start")
+				return nil
+			}
+			c.Log.Error("Ready: campaign: %v", err)
 			HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
 			return nil
 		}
 
-		if channels == nil {
-			c.Log.Error("Ready: channels == nil")
-			HandleError(bot, update, "Каналов не найдено")
-			return nil
-		}
+		return c.ready(ctx, bot, update, campaign, false)
+	}
+}
+
+// ready checks the subscriptions of the user to the campaign channels and sends the invite link to the
+// target channel. Unless solved is set, the captcha is asked first when it is switched on.
+func (c *CallbackHandler) ready(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, campaign *model.Campaign, solved bool) error {
+	userID := update.CallbackQuery.From.ID
 
-		isMember, err := isChatMember(bot, c.Log, channels, update.CallbackQuery.From.ID)
+	captcha, err := c.captchaState(ctx, userID)
+	if err != nil {
+		c.Log.Error("Ready: captchaState: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
+		return nil
+	}
+	if captcha.BlockedUntil.After(time.Now()) {
+		return c.sendCaptchaBlock(ctx, bot, update, captcha.BlockedUntil)
+	}
+
+	channels := campaign.Channels
+	if campaign.Target == nil {
+		c.Log.Error("Ready: campaign %d has no target channel", campaign.ID)
+		HandleError(bot, update, "Каналов не найдено")
+		return nil
+	}
+
+	report := c.Checker.Check(ctx, channels, userID)
+	for _, el := range report.Failed() {
+		c.Log.Error("Ready: channel %d (%s) not checked: %v", el.Channel.ID, el.Channel.Name, el.Err)
+	}
+
+	if !c.Checker.Passed(report) {
+		c.recordVerification(ctx, userID, campaign, report, false)
+	}
+
+	if !c.Checker.Passed(report) && len(report.Missing()) == 0 {
+		HandleError(bot, update, "Не удалось проверить подписку на некоторые каналы, попробуйте позже")
+		return nil
+	}
+
+	if !c.Checker.Passed(report) {
+		recordFunnel(ctx, c.Log, c.FunnelRepo, userID, model.FunnelReadyFailed, campaign.ID)
+		return c.sendMissing(ctx, bot, update, campaign.ID, report.Missing())
+	}
+
+	if !solved {
+		ask, err := c.needsCaptcha(ctx, userID, campaign)
 		if err != nil {
-			c.Log.Error("Ready: isChatMember: %v", err)
+			c.Log.Error("Ready: needsCaptcha: %v", err)
 			HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
 			return nil
 		}
+		// the evaluation is recorded once the captcha is solved
+		if ask {
+			return c.sendCaptcha(ctx, bot, update, campaign.ID, captcha.Attempts)
+		}
+	}
 
-		if !isMember {
-			textThird := "Пожалуйста, подпишитесь на все представленные каналы"
-			msgSec := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, textThird)
+	inviteLink, err := c.issueInviteLink(ctx, bot, userID, campaign)
+	c.recordVerification(ctx, userID, campaign, report, err == nil)
+	if errors.Is(err, errAlreadyMember) {
+		HandleError(bot, update, "Вы уже состоите в канале «"+campaign.Target.Name+"»")
+		return nil
+	}
+	if err != nil {
+		c.Log.Error("Ready: issueInviteLink: %v", err)
+		return err
+	}
+	if err := c.UserRepo.ConfirmReferral(ctx, userID); err != nil {
+		c.Log.Error("Ready: UserRepo.ConfirmReferral: %v", err)
+	}
+	recordFunnel(ctx, c.Log, c.FunnelRepo, userID, model.FunnelLinkIssued, campaign.ID)
 
-			if _, err := bot.Send(msgSec); err != nil {
-				c.Log.Error("failed to send message: %v", err)
-				return err
-			}
+	key := TextInvite
+	if c.JoinRequest {
+		key = TextInviteRequest
+	}
+	textThird := "\n" + inviteLink.InviteLink + "\nСсылка действует до " + inviteLink.ExpiresAt.Format("02.01.2006 15:04")
+	msgSec := telegram.NewMessage(update.CallbackQuery.Message.Chat.ID, withText(c.Texts.Get(ctx, key), textThird))
 
-			return nil
-		} else {
-			channel, err := c.ChRepo.GetByStatus(ctx, model.ChannelStatusMain)
-			if err != nil {
-				c.Log.Error("Ready: ChRepo.GetByStatus: %v", err)
-				HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
-				return nil
-			}
+	if _, err := bot.Send(msgSec); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+	return nil
+}
 
-			if channel == nil || len(channel) == 0 {
-				c.Log.Error("Ready: channel == nil")
-				HandleError(bot, update, "Каналов не найдено")
-				return nil
-			}
+// channelList lists the names of channels one per line.
+func channelList(channels []model.Channel) string {
+	names := make([]string, 0, len(channels))
+	for _, el := range channels {
+		names = append(names, "• "+el.Name)
+	}
+	return strings.Join(names, "\n")
+}
 
-			createLink := tgbotapi.CreateChatInviteLinkConfig{
-				ChatConfig: tgbotapi.ChatConfig{
-					ChatID: channel[0].ChannelTelegramId,
-				},
-				MemberLimit: 1,
-			}
+func readyRow(campaignID int) []tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("ГОТОВО", fmt.Sprintf("ready/%d", campaignID)))
+}
 
-			response, err := bot.Request(createLink)
-			if err != nil {
-				c.Log.Error("update.MyChatMember.Chat: create link error: %v", err)
-				return err
-			}
+// sendMissing replaces the message with the pressed button by the list of channels the user still misses.
+func (c *CallbackHandler) sendMissing(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, campaignID int, missing []model.Channel) error {
+	markup, err := createChannelMarkup(missing, "user")
+	if err != nil {
+		c.Log.Error("sendMissing: createChannelMarkup: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
+		return nil
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, readyRow(campaignID))
 
-			resultByte, err := response.Result.MarshalJSON()
-			if err != nil {
-				c.Log.Error("update.MyChatMember.Chat: response.Result.MarshalJSON: %v", err)
-				return err
-			}
+	text := c.Texts.Render(ctx, TextMissing, "{channels}", channelList(missing))
+	if err := editOrSend(bot, update, text, markup.InlineKeyboard...); err != nil {
+		// the same channels are still missing, the message already shows them
+		if !isNotModified(err) {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
 
-			type InviteLink struct {
-				InviteLink string `json:"invite_link"`
-			}
+		callback := tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, "Подписка на каналы из списка всё ещё не найдена")
+		if _, err := bot.Request(callback); err != nil {
+			c.Log.Error("failed to answer callback: %v", err)
+		}
+		return nil
+	}
 
-			l := &InviteLink{}
-			err = json.Unmarshal(resultByte, l)
-			if err != nil {
-				c.Log.Error("update.MyChatMember.Chat: json.Unmarshal: %v", err)
-				return err
-			}
+	if _, err := bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, "")); err != nil {
+		c.Log.Error("failed to answer callback: %v", err)
+	}
 
-			textThird := "Присоединяйся к секретному каналу:\n" + l.InviteLink
-			msgSec := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, textThird)
+	return nil
+}
 
-			if _, err := bot.Send(msgSec); err != nil {
-				c.Log.Error("failed to send message: %v", err)
-				return err
-			}
-			return nil
-		}
-	}
+func isNotModified(err error) bool {
+	return strings.Contains(err.Error(), "message is not modified")
 }
 
 func (c *CallbackHandler) AdminSetMainChannel() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
 		channels, err := c.ChRepo.GetByStatus(ctx, model.ChannelStatusSecondary)
 		if err != nil {
 			c.Log.Error("Ready: ChRepo.GetByStatus: %v", err)
@@ -219,8 +310,8 @@ func (c *CallbackHandler) AdminSetMainChannel() ViewFunc {
 }
 
 func (c *CallbackHandler) AdminChooseMainChannel() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
-		channelID := model.GetID(update.CallbackData())
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channelID := CallbackParams(ctx).Int("id")
 
 		isExist, id, err := c.ChRepo.IsExistMainChannel(ctx)
 		if err != nil {
@@ -229,7 +320,8 @@ func (c *CallbackHandler) AdminChooseMainChannel() ViewFunc {
 			return nil
 		}
 		if isExist {
-			if err := c.ChRepo.UpdateStatus(ctx, model.ChannelStatusSecondary, id); err != nil {
+			// the former main channel is private, it must not turn into a required subscription
+			if err := c.ChRepo.UpdateStatus(ctx, model.ChannelStatusPrivate, id); err != nil {
 				c.Log.Error("AdminChooseMainChannel: ChRepo.UpdateStatus: %v", err)
 				HandleError(bot, update, "Временные неполадки на сервере")
 				return nil
@@ -254,7 +346,7 @@ func (c *CallbackHandler) AdminChooseMainChannel() ViewFunc {
 }
 
 func (c *CallbackHandler) AdminRoleSetting() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
 		text := "Управление администраторами"
 
 		msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)
@@ -280,7 +372,7 @@ func (c *CallbackHandler) AdminRoleSetting() ViewFunc {
 }
 
 func (c *CallbackHandler) AdminLookUp() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
 		admin, err := c.UserRepo.GetAllAdmin(ctx)
 		if err != nil {
 			c.Log.Error("AdminLookUp: UserRepo.GetAllAdmin: %v", err)
@@ -306,11 +398,11 @@ func (c *CallbackHandler) AdminLookUp() ViewFunc {
 }
 
 func (c *CallbackHandler) AdminDeleteRole() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
-		text := "Напишите никнейм пользователя, у которого вы хотите отозвать права администратором.\nДля отмены команды" +
-			"отправьте /cancel"
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		text := "Напишите никнейм (@username) или ID пользователя, у которого вы хотите отозвать права администратора, " +
+			"или перешлите его сообщение.\nДля отмены команды отправьте /cancel"
 
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+		msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)
 
 		_, err := bot.Send(msg)
 		if err != nil {
@@ -318,18 +410,21 @@ func (c *CallbackHandler) AdminDeleteRole() ViewFunc {
 			return err
 		}
 
-		c.Store.Set(store.AdminStore{
+		if err := c.Store.Set(ctx, store.AdminStore{
 			TypeCommand: store.UserAdminDelete,
-		}, update.CallbackQuery.Message.Chat.ID)
+		}, update.CallbackQuery.Message.Chat.ID); err != nil {
+			c.Log.Error("Store.Set: %v", err)
+			return err
+		}
 
 		return nil
 	}
 }
 
 func (c *CallbackHandler) AdminSetRole() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
-		text := "Напишите никнейм пользователя, которого вы хотите назначить администратором.\nДля отмены команды" +
-			"отправьте /cancel"
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		text := "Напишите никнейм (@username) или ID пользователя, которого вы хотите назначить администратором, " +
+			"или перешлите его сообщение.\nДля отмены команды отправьте /cancel"
 
 		msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)
 
@@ -339,46 +434,13 @@ func (c *CallbackHandler) AdminSetRole() ViewFunc {
 			return err
 		}
 
-		c.Store.Set(store.AdminStore{
+		if err := c.Store.Set(ctx, store.AdminStore{
 			TypeCommand: store.UserAdminCreate,
-		}, update.CallbackQuery.Message.Chat.ID)
-
-		return nil
-	}
-}
-
-func isChatMember(bot *tgbotapi.BotAPI, log *logger.Logger, channels []model.Channel, userID int64) (bool, error) {
-	for _, el := range channels {
-		channel := tgbotapi.ChatInfoConfig{
-			tgbotapi.ChatConfig{
-				ChatID: el.ChannelTelegramId,
-			},
-		}
-		chat, err := bot.GetChat(channel)
-		if err != nil {
-			return false, err
-		}
-
-		cfg := tgbotapi.GetChatMemberConfig{
-			ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
-				ChatID: chat.ID,
-				UserID: userID,
-			},
-		}
-
-		chatMember, err := bot.GetChatMember(cfg)
-		if err != nil {
-			log.Error("error with chatID = %d:%v", chat.ID, err)
-			return false, err
-		}
-
-		switch chatMember.Status {
-		case "creator", "administrator", "member":
-			continue
-		default:
-			return false, nil
+		}, update.CallbackQuery.Message.Chat.ID); err != nil {
+			c.Log.Error("Store.Set: %v", err)
+			return err
 		}
 
+		return nil
 	}
-	return true, nil
 }
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strings"
+	"testing"
+)
+
+func TestReadyThroughFakeClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		subscribed []int64
+		wantLink   bool
+	}{
+		{"subscribed to every channel", []int64{-1001, -1002}, true},
+		{"subscribed to one channel", []int64{-1001}, false},
+		{"not subscribed", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newReadyTest(false)
+			test.subscribe(tt.subscribed...)
+
+			test.press(t)
+
+			checked := make(map[int64]bool)
+			for _, el := range test.bot.Requests() {
+				if member, ok := el.(tgbotapi.GetChatMemberConfig); ok && member.UserID == testUserID {
+					checked[member.ChatID] = true
+				}
+			}
+			if !checked[-1001] || !checked[-1002] {
+				t.Errorf("checked chats = %v, want both campaign channels", checked)
+			}
+
+			if got := test.createdLinks(); (got == 1) != tt.wantLink {
+				t.Fatalf("created links = %d, want link %t", got, tt.wantLink)
+			}
+
+			sent := test.bot.Sent()
+			if len(sent) == 0 {
+				t.Fatal("nothing sent to the user")
+			}
+			msg, isMessage := sent[len(sent)-1].(tgbotapi.MessageConfig)
+			gotLink := isMessage && strings.Contains(msg.Text, "https://t.me/+fake1")
+			if gotLink != tt.wantLink {
+				t.Fatalf("last sent = %+v, want link %t", sent[len(sent)-1], tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestReadyListsMissingChannels(t *testing.T) {
+	test := newReadyTest(false)
+	test.subscribe(-1001)
+
+	test.press(t)
+
+	sent := test.bot.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent = %d messages, want 1", len(sent))
+	}
+	edit, ok := sent[0].(tgbotapi.EditMessageTextConfig)
+	if !ok {
+		t.Fatalf("sent %T, want the edited message", sent[0])
+	}
+	if edit.MessageID != 10 {
+		t.Errorf("edited message %d, want the one with the button", edit.MessageID)
+	}
+	if !strings.Contains(edit.Text, "Второй") || strings.Contains(edit.Text, "Первый") {
+		t.Errorf("text = %q, want only the missing channel", edit.Text)
+	}
+
+	rows := edit.ReplyMarkup.InlineKeyboard
+	if len(rows) != 2 {
+		t.Fatalf("keyboard = %d rows, want the missing channel and ГОТОВО", len(rows))
+	}
+	if url := rows[0][0].URL; url == nil || *url != "https://t.me/second" {
+		t.Errorf("first button url = %v, want the missing channel", url)
+	}
+	if data := rows[1][0].CallbackData; data == nil || *data != "ready/0" {
+		t.Errorf("last button data = %v, want ready/0", data)
+	}
+}
@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
+)
+
+const campaignPayloadPrefix = "c_"
+
+// errForeignCampaign is returned for a button of a campaign the user didn't come with.
+var errForeignCampaign = errors.New("campaign of the button is not the campaign of the user")
+
+// campaign returns the campaign the user came with, or the default campaign. The campaign id of the
+// pressed button must match it, so a forged callback can't open the target channel of another campaign.
+// Buttons sent before campaigns appeared carry no campaign id.
+func (c *CallbackHandler) campaign(ctx context.Context, userID int64) (*model.Campaign, error) {
+	user, err := c.UserRepo.GetUserByID(ctx, userID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	id := model.DefaultCampaignID
+	if err == nil && user.CampaignID != nil {
+		id = *user.CampaignID
+	}
+
+	if _, ok := CallbackParams(ctx)["campaign"]; ok && CallbackParams(ctx).Int("campaign") != id {
+		return nil, errForeignCampaign
+	}
+
+	return c.CampaignRepo.GetByID(ctx, id)
+}
+
+// campaignLink returns the /start deep-link of campaign.
+func (c *CallbackHandler) campaignLink(campaign *model.Campaign) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", c.BotName, campaign.StartPayload())
+}
+
+func newCampaignSlug() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (c *CallbackHandler) AdminCampaigns() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		campaigns, err := c.CampaignRepo.GetAll(ctx)
+		if err != nil {
+			c.Log.Error("AdminCampaigns: CampaignRepo.GetAll: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		text := "Кампании"
+		if len(campaigns) == 0 {
+			text = "Кампаний пока нет"
+		}
+
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, el := range campaigns {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(el.Name, fmt.Sprintf("campaign/%d", el.ID)),
+			))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Создать кампанию", "campaign_create"),
+		))
+
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (c *CallbackHandler) AdminCampaign() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		return c.showCampaign(ctx, bot, update, CallbackParams(ctx).Int("id"))
+	}
+}
+
+func (c *CallbackHandler) showCampaign(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, id int) error {
+	campaign, err := c.CampaignRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			HandleError(bot, update, "Кампания не найдена")
+			return nil
+		}
+		c.Log.Error("showCampaign: CampaignRepo.GetByID: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil
+	}
+
+	var text strings.Builder
+	text.WriteString("Кампания «" + campaign.Name + "»\n")
+	text.WriteString("Целевой канал: " + campaign.Target.Name + "\n")
+	if len(campaign.Channels) == 0 {
+		text.WriteString("Обязательных каналов нет\n")
+	} else {
+		text.WriteString("Обязательные каналы:\n")
+		for _, el := range campaign.Channels {
+			text.WriteString("• " + el.Name + "\n")
+		}
+	}
+	text.WriteString("\nСсылка: " + c.campaignLink(campaign))
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Обязательные каналы", fmt.Sprintf("campaign_channels/%d", campaign.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Удалить", fmt.Sprintf("campaign_delete/%d", campaign.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Назад", "campaigns"),
+		),
+	)
+
+	msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID, text.String(), markup)
+	msg.DisableWebPagePreview = true
+	if _, err := bot.Send(msg); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (c *CallbackHandler) AdminCampaignChannels() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		return c.showCampaignChannels(ctx, bot, update, CallbackParams(ctx).Int("id"))
+	}
+}
+
+// AdminToggleCampaignChannel adds the channel to the required channels of the campaign or removes it.
+func (c *CallbackHandler) AdminToggleCampaignChannel() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		id := CallbackParams(ctx).Int("id")
+		channelID := CallbackParams(ctx).Int("channel")
+
+		campaign, err := c.CampaignRepo.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				HandleError(bot, update, "Кампания не найдена")
+				return nil
+			}
+			c.Log.Error("AdminToggleCampaignChannel: CampaignRepo.GetByID: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		required := false
+		for _, el := range campaign.Channels {
+			if el.ID == channelID {
+				required = true
+			}
+		}
+
+		if required {
+			err = c.CampaignRepo.RemoveChannel(ctx, id, channelID)
+		} else {
+			err = c.CampaignRepo.AddChannel(ctx, id, channelID)
+		}
+		if err != nil {
+			c.Log.Error("AdminToggleCampaignChannel: CampaignRepo: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		return c.showCampaignChannels(ctx, bot, update, id)
+	}
+}
+
+func (c *CallbackHandler) showCampaignChannels(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, id int) error {
+	campaign, err := c.CampaignRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			HandleError(bot, update, "Кампания не найдена")
+			return nil
+		}
+		c.Log.Error("showCampaignChannels: CampaignRepo.GetByID: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil
+	}
+
+	channels, err := c.ChRepo.GetAll(ctx)
+	if err != nil {
+		c.Log.Error("showCampaignChannels: ChRepo.GetAll: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil
+	}
+
+	required := make(map[int]bool)
+	for _, el := range campaign.Channels {
+		required[el.ID] = true
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, el := range channels {
+		if el.ID == campaign.TargetChannelID {
+			continue
+		}
+
+		mark := "▫️ "
+		if required[el.ID] {
+			mark = "✅ "
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+el.Name, fmt.Sprintf("campaign_toggle/%d/%d", campaign.ID, el.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Назад", fmt.Sprintf("campaign/%d", campaign.ID)),
+	))
+
+	text := "Нажмите на канал, чтобы сделать его обязательным для кампании «" + campaign.Name + "» или убрать из обязательных"
+	msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+	if _, err := bot.Send(msg); err != nil && !isNotModified(err) {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// AdminDeleteCampaign asks to confirm the removal of the campaign.
+func (c *CallbackHandler) AdminDeleteCampaign() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		campaign, err := c.CampaignRepo.GetByID(ctx, CallbackParams(ctx).Int("id"))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				HandleError(bot, update, "Кампания не найдена")
+				return nil
+			}
+			c.Log.Error("AdminDeleteCampaign: CampaignRepo.GetByID: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		text := "Удалить кампанию «" + campaign.Name + "»? Ссылка кампании перестанет работать."
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, text, tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("Удалить", fmt.Sprintf("campaign_delete_confirm/%d", campaign.ID)),
+					tgbotapi.NewInlineKeyboardButtonData("Назад", fmt.Sprintf("campaign/%d", campaign.ID)),
+				),
+			))
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (c *CallbackHandler) AdminConfirmDeleteCampaign() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		id := CallbackParams(ctx).Int("id")
+
+		if err := c.CampaignRepo.DeleteByID(ctx, id); err != nil {
+			c.Log.Error("AdminConfirmDeleteCampaign: CampaignRepo.DeleteByID: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+		c.Log.Info("campaign %d deleted by %d", id, update.CallbackQuery.From.ID)
+
+		return c.AdminCampaigns()(ctx, bot, update)
+	}
+}
+
+// AdminCreateCampaign starts creating a campaign: the admin sends its name, then picks the target channel.
+func (c *CallbackHandler) AdminCreateCampaign() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		text := "Напишите название кампании.\nДля отмены команды отправьте /cancel"
+
+		msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		if err := c.Store.Set(ctx, store.CampaignStore{}, update.CallbackQuery.Message.Chat.ID); err != nil {
+			c.Log.Error("Store.Set: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminCampaignName is the state view receiving the name of the campaign being created.
+func (c *CallbackHandler) AdminCampaignName() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		name := strings.TrimSpace(update.Message.Text)
+		if name == "" {
+			HandleError(bot, update, "Название не может быть пустым, напишите название кампании")
+			return nil
+		}
+
+		channels, err := c.ChRepo.GetAll(ctx)
+		if err != nil {
+			c.Log.Error("AdminCampaignName: ChRepo.GetAll: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		if len(channels) == 0 {
+			HandleError(bot, update, "Каналов не найдено, добавьте бота администратором в канал")
+			return nil
+		}
+
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, el := range channels {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(el.Name, fmt.Sprintf("campaign_target/%d", el.ID)),
+			))
+		}
+
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Нажмите на канал, доступ к которому будет открывать кампания")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		if err := c.Store.Set(ctx, store.CampaignStore{Name: name}, update.Message.Chat.ID); err != nil {
+			c.Log.Error("Store.Set: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminCampaignTarget creates the campaign with the chosen target channel.
+func (c *CallbackHandler) AdminCampaignTarget() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		userID := update.CallbackQuery.Message.Chat.ID
+
+		data, exist, err := c.Store.Read(ctx, userID)
+		if err != nil {
+			c.Log.Error("AdminCampaignTarget: Store.Read: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		state, ok := data.(store.CampaignStore)
+		if !exist || !ok || state.Name == "" {
+			HandleError(bot, update, "Кнопка устарела, начните создание кампании заново")
+			return nil
+		}
+
+		slug, err := newCampaignSlug()
+		if err != nil {
+			c.Log.Error("AdminCampaignTarget: newCampaignSlug: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		campaign := &model.Campaign{
+			Name:            state.Name,
+			Slug:            slug,
+			TargetChannelID: CallbackParams(ctx).Int("channel"),
+		}
+		if err := c.CampaignRepo.Create(ctx, campaign); err != nil {
+			c.Log.Error("AdminCampaignTarget: CampaignRepo.Create: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		if err := c.Store.Delete(ctx, userID); err != nil {
+			c.Log.Error("AdminCampaignTarget: Store.Delete: %v", err)
+		}
+
+		return c.showCampaign(ctx, bot, update, campaign.ID)
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"subscriber-check-bot/model"
+	"testing"
+)
+
+func TestCampaignOfButton(t *testing.T) {
+	own := 5
+
+	tests := []struct {
+		name    string
+		user    *model.User
+		params  Params
+		wantErr error
+	}{
+		{"old button of a new user", nil, nil, nil},
+		{"default campaign button", nil, Params{"campaign": "0"}, nil},
+		{"other campaign without one of the user", nil, Params{"campaign": "5"}, errForeignCampaign},
+		{"own campaign button", &model.User{ID: testUserID, CampaignID: &own}, Params{"campaign": "5"}, nil},
+		{"default campaign button of a campaign user", &model.User{ID: testUserID, CampaignID: &own}, Params{"campaign": "0"}, errForeignCampaign},
+		{"other campaign button", &model.User{ID: testUserID, CampaignID: &own}, Params{"campaign": "7"}, errForeignCampaign},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newReadyTest(false)
+			test.users.user = tt.user
+
+			_, err := test.handler.campaign(withParams(context.Background(), tt.params), testUserID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("campaign = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
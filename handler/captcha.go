@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"math/rand/v2"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
+	"subscriber-check-bot/repo"
+	"time"
+)
+
+const (
+	captchaOptions = 8
+	captchaRowSize = 4
+)
+
+// needsCaptcha reports whether the user has to solve the captcha before getting the link. A user who
+// already has a valid link gets it again without one.
+func (c *CallbackHandler) needsCaptcha(ctx context.Context, userID int64, campaign *model.Campaign) (bool, error) {
+	enabled, err := c.SettingRepo.GetBool(ctx, repo.SettingCaptchaEnabled, c.CaptchaEnabled)
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	_, err = c.LinkRepo.GetActive(ctx, userID, campaign.Target.ID, c.JoinRequest)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// captchaState returns the captcha of the user, the zero one if the user has none. The attempts are kept
+// until the captcha is solved or the state expires.
+func (c *CallbackHandler) captchaState(ctx context.Context, userID int64) (store.CaptchaStore, error) {
+	data, exist, err := c.Store.Read(ctx, userID)
+	if err != nil || !exist {
+		return store.CaptchaStore{}, err
+	}
+
+	state, _ := data.(store.CaptchaStore)
+	return state, nil
+}
+
+// sendCaptcha replaces the message with the pressed button by a math question with answer buttons.
+func (c *CallbackHandler) sendCaptcha(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, campaignID int, attempts int) error {
+	a, b := rand.IntN(9)+1, rand.IntN(9)+1
+	answer := a + b
+
+	options := []int{answer}
+	for len(options) < captchaOptions {
+		option := rand.IntN(17) + 2
+		if !containsInt(options, option) {
+			options = append(options, option)
+		}
+	}
+	rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, el := range options {
+		if i%captchaRowSize == 0 {
+			rows = append(rows, nil)
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1],
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprint(el), fmt.Sprintf("captcha/%d/%d", campaignID, el)))
+	}
+
+	text := c.Texts.Render(ctx, TextCaptcha, "{question}", fmt.Sprintf("%d + %d", a, b))
+	if err := editOrSend(bot, update, text, rows...); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	// attempts outlive the usual conversation TTL, so going back to /start doesn't reset them
+	if err := c.Store.SetWithTTL(ctx, store.CaptchaStore{
+		CampaignID: campaignID,
+		Answer:     answer,
+		Attempts:   attempts,
+	}, update.CallbackQuery.From.ID, c.CaptchaBlockDuration); err != nil {
+		c.Log.Error("Store.SetWithTTL: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// sendCaptchaBlock tells the user blocked after too many wrong answers when to try again.
+func (c *CallbackHandler) sendCaptchaBlock(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, blockedUntil time.Time) error {
+	text := c.Texts.Render(ctx, TextCaptchaBlock, "{time}", blockedUntil.Format("15:04"))
+	if _, err := bot.Send(telegram.NewMessage(update.FromChat().ID, text)); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, el := range values {
+		if el == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CaptchaAnswer checks the pressed answer. The right one continues Ready, a wrong one asks a new question
+// until the attempts run out and the user is blocked for CaptchaBlockDuration.
+func (c *CallbackHandler) CaptchaAnswer() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		userID := update.CallbackQuery.From.ID
+		campaignID := CallbackParams(ctx).Int("campaign")
+
+		data, exist, err := c.Store.Read(ctx, userID)
+		if err != nil {
+			c.Log.Error("CaptchaAnswer: Store.Read: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
+			return nil
+		}
+
+		state, ok := data.(store.CaptchaStore)
+		if !exist || !ok || state.CampaignID != campaignID {
+			HandleError(bot, update, "Кнопка устарела, начните заново с /start")
+			return nil
+		}
+
+		if state.BlockedUntil.After(time.Now()) {
+			return c.sendCaptchaBlock(ctx, bot, update, state.BlockedUntil)
+		}
+
+		if CallbackParams(ctx).Int("answer") == state.Answer {
+			if err := c.Store.Delete(ctx, userID); err != nil {
+				c.Log.Error("CaptchaAnswer: Store.Delete: %v", err)
+			}
+
+			campaign, err := c.CampaignRepo.GetByID(ctx, campaignID)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					HandleError(bot, update, "Кампания завершена, начните заново с /start")
+					return nil
+				}
+				c.Log.Error("CaptchaAnswer: CampaignRepo.GetByID: %v", err)
+				HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
+				return nil
+			}
+
+			return c.ready(ctx, bot, update, campaign, true)
+		}
+
+		state.Attempts++
+		if state.Attempts < c.CaptchaMaxAttempts {
+			var text string
+			wrong := c.Texts.Render(ctx, TextCaptchaWrong, "{attempts}", fmt.Sprint(c.CaptchaMaxAttempts-state.Attempts))
+			if wrong.Message != nil {
+				text = *wrong.Message
+			}
+			if _, err := bot.Request(tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, text)); err != nil {
+				c.Log.Error("failed to answer callback: %v", err)
+			}
+			return c.sendCaptcha(ctx, bot, update, campaignID, state.Attempts)
+		}
+
+		blockedUntil := time.Now().Add(c.CaptchaBlockDuration)
+		if err := c.Store.SetWithTTL(ctx, store.CaptchaStore{
+			CampaignID:   campaignID,
+			Attempts:     state.Attempts,
+			BlockedUntil: blockedUntil,
+		}, userID, c.CaptchaBlockDuration); err != nil {
+			c.Log.Error("CaptchaAnswer: Store.SetWithTTL: %v", err)
+			return err
+		}
+		c.Log.Info("user %d blocked until %s after %d captcha attempts", userID, blockedUntil.Format(time.DateTime), state.Attempts)
+
+		text := c.Texts.Render(ctx, TextCaptchaBlock, "{time}", blockedUntil.Format("15:04"))
+		if err := editOrSend(bot, update, text, readyRow(campaignID)); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminCaptcha shows whether the captcha is on with a button to switch it.
+func (c *CallbackHandler) AdminCaptcha() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		enabled, err := c.SettingRepo.GetBool(ctx, repo.SettingCaptchaEnabled, c.CaptchaEnabled)
+		if err != nil {
+			c.Log.Error("AdminCaptcha: SettingRepo.GetBool: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		return c.showCaptcha(bot, update, enabled)
+	}
+}
+
+func (c *CallbackHandler) AdminToggleCaptcha() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		enabled, err := c.SettingRepo.GetBool(ctx, repo.SettingCaptchaEnabled, c.CaptchaEnabled)
+		if err != nil {
+			c.Log.Error("AdminToggleCaptcha: SettingRepo.GetBool: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		enabled = !enabled
+		if err := c.SettingRepo.SetBool(ctx, repo.SettingCaptchaEnabled, enabled); err != nil {
+			c.Log.Error("AdminToggleCaptcha: SettingRepo.SetBool: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+		c.Log.Info("captcha enabled = %t by %d", enabled, update.CallbackQuery.From.ID)
+
+		return c.showCaptcha(bot, update, enabled)
+	}
+}
+
+func (c *CallbackHandler) showCaptcha(bot telegram.Client, update *tgbotapi.Update, enabled bool) error {
+	text, button := "Капча выключена", "Включить"
+	if enabled {
+		text, button = "Капча включена: перед получением ссылки пользователь решает пример", "Выключить"
+	}
+
+	msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID, text, tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(button, "captcha_toggle")),
+		))
+	if _, err := bot.Send(msg); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"subscriber-check-bot/pkg/store"
+	"testing"
+)
+
+func TestReadyAsksCaptcha(t *testing.T) {
+	test := newReadyTest(true)
+	test.subscribe(-1001, -1002)
+
+	test.press(t)
+
+	if got := test.createdLinks(); got != 0 {
+		t.Fatalf("created links = %d, want none before the captcha is solved", got)
+	}
+	if len(test.verifies.verifications) != 0 {
+		t.Fatalf("recorded %d verifications, want none before the captcha is solved", len(test.verifies.verifications))
+	}
+
+	data, exist, err := test.store.Read(context.Background(), testUserID)
+	if err != nil {
+		t.Fatalf("Store.Read: %v", err)
+	}
+	state, ok := data.(store.CaptchaStore)
+	if !exist || !ok || state.CampaignID != test.campaign.ID || state.Attempts != 0 {
+		t.Fatalf("state = %+v, want the captcha of the campaign", data)
+	}
+
+	sent := test.bot.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want the question", len(sent))
+	}
+	edit, ok := sent[0].(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.MessageID != 10 {
+		t.Fatalf("sent %+v, want the question in place of the message with the button", sent[0])
+	}
+	answer := fmt.Sprintf("captcha/%d/%d", test.campaign.ID, state.Answer)
+	var options int
+	var hasAnswer bool
+	for _, row := range edit.ReplyMarkup.InlineKeyboard {
+		for _, el := range row {
+			options++
+			hasAnswer = hasAnswer || (el.CallbackData != nil && *el.CallbackData == answer)
+		}
+	}
+	if options != captchaOptions || !hasAnswer {
+		t.Fatalf("keyboard has %d options, answer %t, want %d options with the answer", options, hasAnswer, captchaOptions)
+	}
+}
+
+func TestCaptchaAnswer(t *testing.T) {
+	tests := []struct {
+		name         string
+		right        bool
+		wantLink     bool
+		wantAttempts int
+	}{
+		{"right answer", true, true, 0},
+		{"wrong answer", false, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newReadyTest(true)
+			test.subscribe(-1001, -1002)
+			test.press(t)
+
+			data, _, err := test.store.Read(context.Background(), testUserID)
+			if err != nil {
+				t.Fatalf("Store.Read: %v", err)
+			}
+			answer := data.(store.CaptchaStore).Answer
+			if !tt.right {
+				answer++
+			}
+
+			ctx := withParams(context.Background(), Params{
+				"campaign": fmt.Sprint(test.campaign.ID),
+				"answer":   fmt.Sprint(answer),
+			})
+			update := &tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+				ID:      "2",
+				From:    &tgbotapi.User{ID: testUserID},
+				Message: &tgbotapi.Message{MessageID: 10, Chat: &tgbotapi.Chat{ID: testChatID}, Text: "ГОТОВО"},
+			}}
+			if err := test.handler.CaptchaAnswer()(ctx, test.bot, update); err != nil {
+				t.Fatalf("CaptchaAnswer: %v", err)
+			}
+
+			if got := test.createdLinks(); (got == 1) != tt.wantLink {
+				t.Fatalf("created links = %d, want link %t", got, tt.wantLink)
+			}
+			if got := len(test.verifies.verifications); (got == 1) != tt.wantLink {
+				t.Fatalf("recorded %d verifications, want one only after the right answer", got)
+			}
+
+			data, exist, err := test.store.Read(context.Background(), testUserID)
+			if err != nil {
+				t.Fatalf("Store.Read: %v", err)
+			}
+			if state, _ := data.(store.CaptchaStore); exist != !tt.right || state.Attempts != tt.wantAttempts {
+				t.Fatalf("state = %+v, exists %t, want %d attempts", data, exist, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
+)
+
+// AdminChannels lists every channel the bot administers with its status and link. The arrows move
+// the channel up and down the list users see.
+func (c *CallbackHandler) AdminChannels() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channels, err := c.ChRepo.GetAll(ctx)
+		if err != nil {
+			c.Log.Error("AdminChannels: ChRepo.GetAll: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		text, markup := channelsView(channels)
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, text, markup)
+		msg.DisableWebPagePreview = true
+		if _, err := bot.Send(msg); err != nil && !isNotModified(err) {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func channelsView(channels []model.Channel) (string, tgbotapi.InlineKeyboardMarkup) {
+	if len(channels) == 0 {
+		return "Каналов не найдено. Добавьте бота администратором в канал, и он появится здесь",
+			tgbotapi.NewInlineKeyboardMarkup()
+	}
+
+	var text strings.Builder
+	text.WriteString("Каналы\n")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, el := range channels {
+		fmt.Fprintf(&text, "\n%d. %s — %s\n%s\n", i+1, el.Name, channelStatusText(el.ChannelStatus), el.URL)
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(el.Name, fmt.Sprintf("channel/%d", el.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("↑", fmt.Sprintf("channel_up/%d", el.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("↓", fmt.Sprintf("channel_down/%d", el.ID)),
+		))
+	}
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func channelStatusText(status model.Status) string {
+	switch status {
+	case model.ChannelStatusMain:
+		return "главный"
+	case model.ChannelStatusPrivate:
+		return "закрытый, не показывается пользователям"
+	default:
+		return "дополнительный"
+	}
+}
+
+func (c *CallbackHandler) AdminChannel() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channel, ok := c.callbackChannel(ctx, bot, update)
+		if !ok {
+			return nil
+		}
+
+		return c.showChannel(bot, update, channel)
+	}
+}
+
+func (c *CallbackHandler) AdminMoveChannelUp() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		return c.moveChannel(ctx, bot, update, -1)
+	}
+}
+
+func (c *CallbackHandler) AdminMoveChannelDown() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		return c.moveChannel(ctx, bot, update, 1)
+	}
+}
+
+// moveChannel swaps the channel with its neighbour and renumbers the list, so channels sharing
+// a sort order move as well.
+func (c *CallbackHandler) moveChannel(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, step int) error {
+	id := CallbackParams(ctx).Int("id")
+
+	channels, err := c.ChRepo.GetAll(ctx)
+	if err != nil {
+		c.Log.Error("moveChannel: ChRepo.GetAll: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil
+	}
+
+	i := -1
+	for j, el := range channels {
+		if el.ID == id {
+			i = j
+			break
+		}
+	}
+	if i == -1 {
+		HandleError(bot, update, "Канал не найден")
+		return nil
+	}
+
+	j := i + step
+	if j < 0 || j >= len(channels) {
+		return nil
+	}
+	channels[i], channels[j] = channels[j], channels[i]
+
+	ids := make([]int, len(channels))
+	for k, el := range channels {
+		ids[k] = el.ID
+		channels[k].SortOrder = k + 1
+	}
+
+	if err := c.ChRepo.UpdateSortOrder(ctx, ids); err != nil {
+		c.Log.Error("moveChannel: ChRepo.UpdateSortOrder: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil
+	}
+
+	text, markup := channelsView(channels)
+	msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID, text, markup)
+	msg.DisableWebPagePreview = true
+	if _, err := bot.Send(msg); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// AdminDemoteChannel asks to confirm taking the main status away from the channel.
+func (c *CallbackHandler) AdminDemoteChannel() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channel, ok := c.callbackChannel(ctx, bot, update)
+		if !ok {
+			return nil
+		}
+
+		text := "Снять статус главного с канала «" + channel.Name + "»? Канал станет закрытым и не будет показываться " +
+			"пользователям, пока не будет выбран новый главный канал, ссылки на вступление выдаваться не будут."
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, text, tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("Снять", fmt.Sprintf("channel_demote_confirm/%d", channel.ID)),
+					tgbotapi.NewInlineKeyboardButtonData("Назад", fmt.Sprintf("channel/%d", channel.ID)),
+				),
+			))
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminConfirmDemoteChannel makes the main channel private: its link must not become a required
+// subscription of the default campaign.
+func (c *CallbackHandler) AdminConfirmDemoteChannel() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		return c.setChannelStatus(ctx, bot, update, model.ChannelStatusPrivate)
+	}
+}
+
+// AdminShowChannel makes a private channel secondary, offering it to users again.
+func (c *CallbackHandler) AdminShowChannel() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		return c.setChannelStatus(ctx, bot, update, model.ChannelStatusSecondary)
+	}
+}
+
+func (c *CallbackHandler) setChannelStatus(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, status model.Status) error {
+	channel, ok := c.callbackChannel(ctx, bot, update)
+	if !ok {
+		return nil
+	}
+
+	if err := c.ChRepo.UpdateStatus(ctx, status, channel.ID); err != nil {
+		c.Log.Error("setChannelStatus: ChRepo.UpdateStatus: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil
+	}
+	c.Log.Info("channel %d status %s set by %d", channel.ID, status, update.CallbackQuery.From.ID)
+
+	channel.ChannelStatus = status
+	return c.showChannel(bot, update, channel)
+}
+
+// channelLinkConfig is the link a channel is listed with, both when the bot is added to the channel
+// and when an admin replaces the link.
+func channelLinkConfig(chatID int64) tgbotapi.CreateChatInviteLinkConfig {
+	return tgbotapi.CreateChatInviteLinkConfig{
+		ChatConfig: tgbotapi.ChatConfig{
+			ChatID: chatID,
+		},
+		CreatesJoinRequest: true,
+	}
+}
+
+// AdminRegenerateChannelLink replaces the link users subscribe by and revokes the old one.
+func (c *CallbackHandler) AdminRegenerateChannelLink() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channel, ok := c.callbackChannel(ctx, bot, update)
+		if !ok {
+			return nil
+		}
+
+		inviteLink, err := bot.CreateChatInviteLink(channelLinkConfig(channel.ChannelTelegramId))
+		if err != nil {
+			c.Log.Error("AdminRegenerateChannelLink: CreateChatInviteLink: %v", err)
+			HandleError(bot, update, "Не удалось создать ссылку, проверьте, что бот может приглашать пользователей в канал")
+			return nil
+		}
+
+		if err := c.ChRepo.UpdateURL(ctx, inviteLink.InviteLink, channel.ID); err != nil {
+			c.Log.Error("AdminRegenerateChannelLink: ChRepo.UpdateURL: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		revoke := tgbotapi.RevokeChatInviteLinkConfig{
+			ChatConfig: tgbotapi.ChatConfig{ChatID: channel.ChannelTelegramId},
+			InviteLink: channel.URL,
+		}
+		// the old link may be public or already gone
+		if _, err := bot.Request(revoke); err != nil {
+			c.Log.Info("AdminRegenerateChannelLink: revoke old link of channel %d: %v", channel.ID, err)
+		}
+
+		channel.URL = inviteLink.InviteLink
+		return c.showChannel(bot, update, channel)
+	}
+}
+
+func (c *CallbackHandler) AdminRenameChannel() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		text := "Напишите новое название канала, которое увидят пользователи.\nДля отмены команды отправьте /cancel"
+		if _, err := bot.Send(tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		if err := c.Store.Set(ctx, store.ChannelRenameStore{
+			ChannelID: CallbackParams(ctx).Int("id"),
+		}, update.CallbackQuery.Message.Chat.ID); err != nil {
+			c.Log.Error("Store.Set: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminChannelName is the state view receiving the new name of the channel.
+func (c *CallbackHandler) AdminChannelName() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		state, ok := State(ctx).(store.ChannelRenameStore)
+		if !ok {
+			return nil
+		}
+
+		name := strings.TrimSpace(update.Message.Text)
+		if name == "" || len([]rune(name)) > 200 {
+			HandleError(bot, update, "Название должно быть от 1 до 200 символов")
+			return nil
+		}
+
+		channel, err := c.ChRepo.GetByID(ctx, state.ChannelID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				HandleError(bot, update, "Канал не найден")
+				return c.Store.Delete(ctx, update.Message.Chat.ID)
+			}
+			c.Log.Error("AdminChannelName: ChRepo.GetByID: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		if err := c.ChRepo.UpdateName(ctx, name, channel.ID); err != nil {
+			c.Log.Error("AdminChannelName: ChRepo.UpdateName: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		if err := c.Store.Delete(ctx, update.Message.Chat.ID); err != nil {
+			c.Log.Error("AdminChannelName: Store.Delete: %v", err)
+		}
+
+		channel.Name = name
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, channelText(channel))
+		msg.ReplyMarkup = channelMarkup(channel)
+		msg.DisableWebPagePreview = true
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminDeleteChannel asks to confirm the removal, campaigns leading to the channel are removed with it.
+func (c *CallbackHandler) AdminDeleteChannel() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channel, ok := c.callbackChannel(ctx, bot, update)
+		if !ok {
+			return nil
+		}
+
+		text := "Удалить канал «" + channel.Name + "»? Кампании, ведущие в этот канал, будут удалены вместе с ним. " +
+			"Чтобы вернуть канал в список, удалите бота из канала и добавьте снова."
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, text, tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("Удалить", fmt.Sprintf("channel_delete_confirm/%d", channel.ID)),
+					tgbotapi.NewInlineKeyboardButtonData("Назад", fmt.Sprintf("channel/%d", channel.ID)),
+				),
+			))
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (c *CallbackHandler) AdminConfirmDeleteChannel() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		id := CallbackParams(ctx).Int("id")
+
+		if err := c.ChRepo.DeleteByID(ctx, id); err != nil {
+			c.Log.Error("AdminConfirmDeleteChannel: ChRepo.DeleteByID: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+		c.Log.Info("channel %d deleted by %d", id, update.CallbackQuery.From.ID)
+
+		return c.AdminChannels()(ctx, bot, update)
+	}
+}
+
+func (c *CallbackHandler) showChannel(bot telegram.Client, update *tgbotapi.Update, channel *model.Channel) error {
+	msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID, channelText(channel), channelMarkup(channel))
+	msg.DisableWebPagePreview = true
+	if _, err := bot.Send(msg); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func channelText(channel *model.Channel) string {
+	return fmt.Sprintf("Канал «%s»\nСтатус: %s\nTelegram ID: %d\nСсылка: %s",
+		channel.Name, channelStatusText(channel.ChannelStatus), channel.ChannelTelegramId, channel.URL)
+}
+
+func channelMarkup(channel *model.Channel) tgbotapi.InlineKeyboardMarkup {
+	status := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Сделать главным", fmt.Sprintf("channel_set/%d", channel.ID)),
+	)
+	switch channel.ChannelStatus {
+	case model.ChannelStatusMain:
+		status = tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Снять статус главного", fmt.Sprintf("channel_demote/%d", channel.ID)),
+		)
+	case model.ChannelStatusPrivate:
+		status = append(status,
+			tgbotapi.NewInlineKeyboardButtonData("Показывать пользователям", fmt.Sprintf("channel_show/%d", channel.ID)))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Переименовать", fmt.Sprintf("channel_rename/%d", channel.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("Новая ссылка", fmt.Sprintf("channel_link/%d", channel.ID)),
+		),
+		status,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Правила", fmt.Sprintf("channel_rule/%d", channel.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("Удалить", fmt.Sprintf("channel_delete/%d", channel.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Назад", "channels"),
+		),
+	)
+}
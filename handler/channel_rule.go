@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strconv"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
+	"time"
+)
+
+const ruleDateLayout = "02.01.2006"
+
+func (c *CallbackHandler) AdminChannelRules() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channels, err := c.ChRepo.GetAll(ctx)
+		if err != nil {
+			c.Log.Error("AdminChannelRules: ChRepo.GetAll: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		if len(channels) == 0 {
+			HandleError(bot, update, "Каналов не найдено")
+			return nil
+		}
+
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, el := range channels {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(el.Name, fmt.Sprintf("channel_rule/%d", el.ID)),
+			))
+		}
+
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, "Нажмите на канал, чтобы изменить его правила", tgbotapi.NewInlineKeyboardMarkup(rows...))
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (c *CallbackHandler) AdminChannelRule() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channel, ok := c.callbackChannel(ctx, bot, update)
+		if !ok {
+			return nil
+		}
+
+		return c.showChannelRule(bot, update, channel)
+	}
+}
+
+// AdminToggleChannelRequired switches the channel between required and optional.
+func (c *CallbackHandler) AdminToggleChannelRequired() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channel, ok := c.callbackChannel(ctx, bot, update)
+		if !ok {
+			return nil
+		}
+
+		channel.IsRequired = !channel.IsRequired
+		if err := c.ChRepo.UpdateRules(ctx, channel); err != nil {
+			c.Log.Error("AdminToggleChannelRequired: ChRepo.UpdateRules: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		return c.showChannelRule(bot, update, channel)
+	}
+}
+
+// AdminToggleChannelRestricted switches whether restricted members still in the channel count as subscribed.
+func (c *CallbackHandler) AdminToggleChannelRestricted() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		channel, ok := c.callbackChannel(ctx, bot, update)
+		if !ok {
+			return nil
+		}
+
+		channel.AllowRestricted = !channel.AllowRestricted
+		if err := c.ChRepo.UpdateRules(ctx, channel); err != nil {
+			c.Log.Error("AdminToggleChannelRestricted: ChRepo.UpdateRules: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		return c.showChannelRule(bot, update, channel)
+	}
+}
+
+func (c *CallbackHandler) AdminChannelPeriod() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		text := "Напишите период, в который канал обязателен, в формате ДД.ММ.ГГГГ ДД.ММ.ГГГГ. " +
+			"Вместо любой из дат можно написать -, чтобы не ограничивать период с этой стороны.\n" +
+			"Для отмены команды отправьте /cancel"
+
+		return c.askChannelRule(ctx, bot, update, store.ChannelRulePeriod, text)
+	}
+}
+
+func (c *CallbackHandler) AdminChannelOrder() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		text := "Напишите порядковый номер канала, каналы показываются по возрастанию номера.\n" +
+			"Для отмены команды отправьте /cancel"
+
+		return c.askChannelRule(ctx, bot, update, store.ChannelRuleOrder, text)
+	}
+}
+
+func (c *CallbackHandler) askChannelRule(ctx context.Context, bot telegram.Client, update *tgbotapi.Update,
+	field store.ChannelRuleField, text string) error {
+	msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)
+	if _, err := bot.Send(msg); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	if err := c.Store.Set(ctx, store.ChannelRuleStore{
+		ChannelID: CallbackParams(ctx).Int("id"),
+		Field:     field,
+	}, update.CallbackQuery.Message.Chat.ID); err != nil {
+		c.Log.Error("Store.Set: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// AdminChannelRuleValue is the state view receiving the period or the order of the channel.
+func (c *CallbackHandler) AdminChannelRuleValue() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		state, ok := State(ctx).(store.ChannelRuleStore)
+		if !ok {
+			return nil
+		}
+
+		channel, err := c.ChRepo.GetByID(ctx, state.ChannelID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				HandleError(bot, update, "Канал не найден")
+				return c.Store.Delete(ctx, update.Message.Chat.ID)
+			}
+			c.Log.Error("AdminChannelRuleValue: ChRepo.GetByID: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		text := strings.TrimSpace(update.Message.Text)
+
+		switch state.Field {
+		case store.ChannelRulePeriod:
+			startsAt, endsAt, err := parsePeriod(text, c.Location)
+			if err != nil {
+				HandleError(bot, update, "Неверный формат периода, напишите например 01.10.2026 31.10.2026")
+				return nil
+			}
+			channel.StartsAt, channel.EndsAt = startsAt, endsAt
+		case store.ChannelRuleOrder:
+			order, err := strconv.Atoi(text)
+			if err != nil {
+				HandleError(bot, update, "Порядковый номер должен быть числом")
+				return nil
+			}
+			channel.SortOrder = order
+		}
+
+		if err := c.ChRepo.UpdateRules(ctx, channel); err != nil {
+			c.Log.Error("AdminChannelRuleValue: ChRepo.UpdateRules: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		if err := c.Store.Delete(ctx, update.Message.Chat.ID); err != nil {
+			c.Log.Error("AdminChannelRuleValue: Store.Delete: %v", err)
+		}
+
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, channelRuleText(channel, c.Location))
+		msg.ReplyMarkup = channelRuleMarkup(channel)
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// parsePeriod parses "from to" dates in location, "-" leaves the side open. The end date is included in the period.
+func parsePeriod(text string, location *time.Location) (*time.Time, *time.Time, error) {
+	parts := strings.Fields(text)
+	if len(parts) == 1 && parts[0] == "-" {
+		return nil, nil, nil
+	}
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("period %q: want two dates", text)
+	}
+
+	var dates [2]*time.Time
+	for i, el := range parts {
+		if el == "-" {
+			continue
+		}
+
+		date, err := time.ParseInLocation(ruleDateLayout, el, location)
+		if err != nil {
+			return nil, nil, err
+		}
+		dates[i] = &date
+	}
+
+	if dates[1] != nil {
+		end := dates[1].AddDate(0, 0, 1)
+		dates[1] = &end
+	}
+
+	if dates[0] != nil && dates[1] != nil && !dates[0].Before(*dates[1]) {
+		return nil, nil, fmt.Errorf("period %q: start after end", text)
+	}
+
+	return dates[0], dates[1], nil
+}
+
+func (c *CallbackHandler) callbackChannel(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) (*model.Channel, bool) {
+	channel, err := c.ChRepo.GetByID(ctx, CallbackParams(ctx).Int("id"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			HandleError(bot, update, "Канал не найден")
+			return nil, false
+		}
+		c.Log.Error("callbackChannel: ChRepo.GetByID: %v", err)
+		HandleError(bot, update, "Временные неполадки на сервере")
+		return nil, false
+	}
+
+	return channel, true
+}
+
+func (c *CallbackHandler) showChannelRule(bot telegram.Client, update *tgbotapi.Update, channel *model.Channel) error {
+	msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+		update.CallbackQuery.Message.MessageID, channelRuleText(channel, c.Location), channelRuleMarkup(channel))
+	if _, err := bot.Send(msg); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func channelRuleText(channel *model.Channel, location *time.Location) string {
+	required := "да"
+	if !channel.IsRequired {
+		required = "нет, показывается по желанию"
+	}
+
+	restricted := "не засчитываются"
+	if channel.AllowRestricted {
+		restricted = "засчитываются"
+	}
+
+	period := "без ограничений"
+	if channel.StartsAt != nil || channel.EndsAt != nil {
+		from, to := "-", "-"
+		if channel.StartsAt != nil {
+			from = channel.StartsAt.In(location).Format(ruleDateLayout)
+		}
+		if channel.EndsAt != nil {
+			to = channel.EndsAt.In(location).AddDate(0, 0, -1).Format(ruleDateLayout)
+		}
+		period = from + " — " + to
+	}
+
+	var text strings.Builder
+	text.WriteString("Правила канала «" + channel.Name + "»\n")
+	text.WriteString("Обязательный: " + required + "\n")
+	text.WriteString("Ограниченные участники: " + restricted + "\n")
+	text.WriteString("Период: " + period + "\n")
+	text.WriteString("Порядковый номер: " + strconv.Itoa(channel.SortOrder))
+
+	return text.String()
+}
+
+func channelRuleMarkup(channel *model.Channel) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Обязательный / по желанию", fmt.Sprintf("channel_rule_required/%d", channel.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Ограниченные участники", fmt.Sprintf("channel_rule_restricted/%d", channel.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Период", fmt.Sprintf("channel_rule_period/%d", channel.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("Порядок", fmt.Sprintf("channel_rule_order/%d", channel.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Назад", "channel_rules"),
+		),
+	)
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	date := func(year int, month time.Month, day int) *time.Time {
+		d := time.Date(year, month, day, 0, 0, 0, 0, moscow)
+		return &d
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		wantStart *time.Time
+		wantEnd   *time.Time
+		wantErr   bool
+	}{
+		{"no period", "-", nil, nil, false},
+		{"both dates, the end day included", "01.03.2025 31.03.2025", date(2025, 3, 1), date(2025, 4, 1), false},
+		{"single day", "01.03.2025 01.03.2025", date(2025, 3, 1), date(2025, 3, 2), false},
+		{"open start", "- 31.03.2025", nil, date(2025, 4, 1), false},
+		{"open end", "01.03.2025 -", date(2025, 3, 1), nil, false},
+		{"extra spaces", "  01.03.2025   31.03.2025 ", date(2025, 3, 1), date(2025, 4, 1), false},
+		{"start after end", "31.03.2025 01.03.2025", nil, nil, true},
+		{"one date", "01.03.2025", nil, nil, true},
+		{"three dates", "01.03.2025 02.03.2025 03.03.2025", nil, nil, true},
+		{"wrong layout", "2025-03-01 2025-03-31", nil, nil, true},
+		{"empty", "", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parsePeriod(tt.text, moscow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePeriod(%q) error = %v, want error %t", tt.text, err, tt.wantErr)
+			}
+			if !sameTime(start, tt.wantStart) || !sameTime(end, tt.wantEnd) {
+				t.Fatalf("parsePeriod(%q) = %v, %v, want %v, %v", tt.text, start, end, tt.wantStart, tt.wantEnd)
+			}
+			if start != nil && start.Location() != moscow {
+				t.Errorf("start in %s, want the location of the admin", start.Location())
+			}
+		})
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -3,9 +3,10 @@ package handler
 import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"log"
+	"subscriber-check-bot/pkg/telegram"
 )
 
-func HandleError(bot *tgbotapi.BotAPI, update *tgbotapi.Update, messageError string) {
+func HandleError(bot telegram.Client, update *tgbotapi.Update, messageError string) {
 	msg := tgbotapi.NewMessage(update.FromChat().ID, messageError)
 	_, err := bot.Send(msg)
 	if err != nil {
@@ -3,11 +3,16 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"runtime/debug"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/config"
 	"subscriber-check-bot/model"
 	"subscriber-check-bot/pkg/logger"
 	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
+	"subscriber-check-bot/pkg/worker"
 	"subscriber-check-bot/repo"
 	"sync"
 	"time"
@@ -15,77 +20,219 @@ import (
 
 const InternalServerError = "internal server error"
 
-type ViewFunc func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error
+// allowedUpdates are the update types requested from Telegram. chat_member is not sent by default.
+var allowedUpdates = []string{"message", "callback_query", "chat_join_request", "my_chat_member", "chat_member"}
+
+type ViewFunc func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error
 
 type Bot struct {
-	bot   *tgbotapi.BotAPI
+	bot   telegram.Client
 	log   *logger.Logger
-	store *store.Store
-
-	chRepo   repo.ChannelRepo
-	msgRepo  repo.MessageRepo
-	userRepo repo.UserRepo
-
-	cmdView      map[string]ViewFunc
-	callbackView map[string]ViewFunc
+	cfg   *config.Config
+	store store.Store
+
+	chRepo     repo.ChannelRepo
+	msgRepo    repo.MessageRepo
+	userRepo   repo.UserRepo
+	updateRepo repo.UpdateRepo
+
+	cmdView         map[string]ViewFunc
+	callbackView    router
+	chatMemberView  ViewFunc
+	joinRequestView ViewFunc
+	stateView       map[string]ViewFunc
+	middlewares     []Middleware
+	handler         ViewFunc
+	stopping        chan struct{}
 
 	mu      sync.RWMutex
 	isDebug bool
 }
 
-func NewBot(bot *tgbotapi.BotAPI,
+func NewBot(bot telegram.Client,
 	log *logger.Logger,
+	cfg *config.Config,
 	chRepo repo.ChannelRepo,
 	msgRepo repo.MessageRepo,
 	userRepo repo.UserRepo,
-	store *store.Store,
+	updateRepo repo.UpdateRepo,
+	store store.Store,
 ) *Bot {
 	return &Bot{
-		bot:      bot,
-		log:      log,
-		chRepo:   chRepo,
-		msgRepo:  msgRepo,
-		userRepo: userRepo,
-		store:    store,
+		bot:        bot,
+		log:        log,
+		cfg:        cfg,
+		chRepo:     chRepo,
+		msgRepo:    msgRepo,
+		userRepo:   userRepo,
+		updateRepo: updateRepo,
+		store:      store,
+		stopping:   make(chan struct{}),
 	}
 }
 
-func (b *Bot) RegisterCommandView(cmd string, view ViewFunc) {
+// Use adds middlewares wrapping the handling of every update, including updates no view is registered for.
+func (b *Bot) Use(middlewares ...Middleware) {
+	b.middlewares = append(b.middlewares, middlewares...)
+}
+
+// Group returns a group whose views are wrapped with middlewares.
+func (b *Bot) Group(middlewares ...Middleware) *Group {
+	return &Group{bot: b, middlewares: middlewares}
+}
+
+func (b *Bot) RegisterCommandView(cmd string, view ViewFunc, middlewares ...Middleware) {
 	if b.cmdView == nil {
 		b.cmdView = make(map[string]ViewFunc)
 	}
 
-	b.cmdView[cmd] = view
+	b.cmdView[cmd] = Chain(view, middlewares...)
 }
 
-func (b *Bot) RegisterCommandCallback(callback string, view ViewFunc) {
-	if b.callbackView == nil {
-		b.callbackView = make(map[string]ViewFunc)
+// RegisterCommandCallback registers view for callback data matching pattern, e.g. "channel_set/{id:int}".
+// Params are available in the view through CallbackParams.
+func (b *Bot) RegisterCommandCallback(pattern string, view ViewFunc, middlewares ...Middleware) {
+	b.callbackView.handle(pattern, Chain(view, middlewares...))
+}
+
+// RegisterChatMember registers view for changes of members in chats where the bot is an administrator.
+func (b *Bot) RegisterChatMember(view ViewFunc, middlewares ...Middleware) {
+	b.chatMemberView = Chain(view, middlewares...)
+}
+
+// RegisterChatJoinRequest registers view for join requests to chats where the bot is an administrator.
+func (b *Bot) RegisterChatJoinRequest(view ViewFunc, middlewares ...Middleware) {
+	b.joinRequestView = Chain(view, middlewares...)
+}
+
+// RegisterStateView registers view for messages of users whose conversation state is of kind.
+// The state is available in the view through State.
+func (b *Bot) RegisterStateView(kind string, view ViewFunc, middlewares ...Middleware) {
+	if b.stateView == nil {
+		b.stateView = make(map[string]ViewFunc)
 	}
 
-	b.callbackView[callback] = view
+	b.stateView[kind] = Chain(view, middlewares...)
 }
 
+// Run handles updates until ctx is done, then shuts down gracefully: it stops receiving updates and
+// gives in-flight handlers the grace period to finish before cancelling their contexts.
 func (b *Bot) Run(ctx context.Context) error {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	b.handler = Chain(b.dispatch, b.middlewares...)
+
+	rootCtx, cancelRoot := context.WithCancel(context.Background())
+	defer cancelRoot()
+
+	offset, err := b.updateRepo.GetOffset(ctx)
+	if err != nil {
+		return fmt.Errorf("updateRepo.GetOffset: %w", err)
+	}
+	tracker := newOffsetTracker(offset)
+
+	go b.cleanupProcessed(rootCtx)
+
+	pool := worker.New(b.cfg.Worker.Count, b.cfg.Worker.QueueSize)
+
+	updates, stop, serveErr, err := b.receiveUpdates(offset)
+	if err != nil {
+		pool.Close()
+		return err
+	}
 
-	updates := b.bot.GetUpdatesChan(u)
 	for {
 		select {
-		case update := <-updates:
-			updateCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		case update, ok := <-updates:
+			if !ok {
+				return b.shutdown(stop, pool, cancelRoot)
+			}
 
 			b.isDebug = false
 			b.jsonDebug(update.MyChatMember)
 
-			b.handlerUpdate(updateCtx, &update)
+			tracker.received(update.UpdateID)
+
+			// blocks while the worker queue is full, so slow handlers slow down polling instead of losing updates
+			if err := pool.Submit(ctx, updateKey(&update), func() {
+				if rootCtx.Err() != nil {
+					return
+				}
+				b.handlerUpdate(rootCtx, &update)
 
-			cancel()
+				if offset, ok := tracker.done(update.UpdateID); ok {
+					b.saveOffset(offset)
+				}
+			}); err != nil {
+				if ctx.Err() != nil {
+					return b.shutdown(stop, pool, cancelRoot)
+				}
+				return err
+			}
+		case err := <-serveErr:
+			if err := b.shutdown(stop, pool, cancelRoot); err != nil {
+				b.log.Error("%v", err)
+			}
+			return fmt.Errorf("webhook: %w", err)
 		case <-ctx.Done():
-			return ctx.Err()
+			return b.shutdown(stop, pool, cancelRoot)
+		}
+	}
+}
+
+func (b *Bot) shutdown(stop func(), pool *worker.Pool, cancelRoot context.CancelFunc) error {
+	b.log.Info("shutting down: waiting up to %s for in-flight updates", b.cfg.Worker.GracePeriod)
+
+	close(b.stopping)
+	stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Worker.GracePeriod)
+	defer cancel()
+
+	if err := pool.Shutdown(ctx); err != nil {
+		b.log.Error("shutdown: grace period is over, cancelling in-flight updates")
+		cancelRoot()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := pool.Shutdown(ctx); err != nil {
+			return fmt.Errorf("shutdown: handlers did not stop after cancel: %w", err)
 		}
 	}
+
+	b.log.Info("shutdown: all updates handled")
+	return nil
+}
+
+// receiveUpdates starts receiving updates in the mode chosen in config. The returned func stops receiving,
+// the error channel reports the webhook server failing and is nil when polling.
+func (b *Bot) receiveUpdates(offset int) (tgbotapi.UpdatesChannel, func(), <-chan error, error) {
+	if b.cfg.Telegram.Mode == config.ModeWebhook {
+		return b.listenWebhook()
+	}
+
+	u := tgbotapi.NewUpdate(offset)
+	u.Timeout = 60
+	u.AllowedUpdates = allowedUpdates
+
+	return b.bot.GetUpdatesChan(u), b.bot.StopReceivingUpdates, nil, nil
+}
+
+// updateKey returns the id updates are ordered by: the sender if there is one, otherwise the chat.
+func updateKey(update *tgbotapi.Update) int64 {
+	switch {
+	case update.SentFrom() != nil:
+		return update.SentFrom().ID
+	case update.ChatJoinRequest != nil:
+		return update.ChatJoinRequest.From.ID
+	case update.MyChatMember != nil:
+		return update.MyChatMember.Chat.ID
+	case update.ChatMember != nil:
+		return update.ChatMember.Chat.ID
+	case update.ChannelPost != nil:
+		return update.ChannelPost.Chat.ID
+	default:
+		return int64(update.UpdateID)
+	}
 }
 
 func (b *Bot) jsonDebug(update any) {
@@ -99,118 +246,82 @@ func (b *Bot) jsonDebug(update any) {
 }
 
 func (b *Bot) handlerUpdate(ctx context.Context, update *tgbotapi.Update) {
-	defer func() {
-		if p := recover(); p != nil {
-			b.log.Error("panic recovered: %v, %s", p, string(debug.Stack()))
+	if err := b.handler(ctx, b.bot, update); err != nil {
+		b.log.Error("failed to handle update: %v", err)
+		if update.FromChat() != nil {
+			HandleError(b.bot, update, InternalServerError)
 		}
-	}()
+	}
+}
 
+// dispatch routes update to the registered views. Global middlewares wrap it as a whole.
+func (b *Bot) dispatch(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
 	// if write message
 	if update.Message != nil {
-		b.log.Info("[%s] %s", update.Message.From.UserName, update.Message.Text)
-
-		isUserExist, err := b.userRepo.IsUserExistByUserID(ctx, update.Message.From.ID)
-		if err != nil {
-			b.log.Error("userRepo.IsUserExistByUserID: %v", err)
-			HandleError(b.bot, update, InternalServerError)
-			return
-		}
-		if !isUserExist {
-			if err := b.userRepo.CreateUser(ctx, &model.User{
-				ID:         update.Message.From.ID,
-				UsernameTg: update.Message.From.UserName,
-				CreatedAt:  time.Now(),
-				Role:       "user",
-			}); err != nil {
-				b.log.Error("userService.CreateUser: failed to create user: %v", err)
-				return
+		// /cancel must reach its view even in the middle of a conversation
+		if update.Message.Command() != "cancel" {
+			isStoreExist := b.isStoreExist(ctx, update)
+			if isStoreExist {
+				return nil
 			}
 		}
 
-		isStoreExist := b.isStoreExist(ctx, update)
-		if isStoreExist {
-			return
-		}
-
-		var view ViewFunc
-
-		cmd := update.Message.Command()
-
-		cmdView, ok := b.cmdView[cmd]
+		cmdView, ok := b.cmdView[update.Message.Command()]
 		if !ok {
-			return
+			return nil
 		}
 
-		view = cmdView
-
-		if err := view(ctx, b.bot, update); err != nil {
-			b.log.Error("failed to handle update: %v", err)
-			HandleError(b.bot, update, InternalServerError)
-			return
-		}
+		return cmdView(ctx, bot, update)
 		//  if press button
 	} else if update.CallbackQuery != nil {
-		b.log.Info("[%s] %s", update.CallbackQuery.From.UserName, update.CallbackData())
-
-		var callback ViewFunc
-
-		err, callbackView := b.CallbackStrings(update.CallbackData())
+		callback, params, err := b.callbackView.match(update.CallbackData())
 		if err != nil {
-			b.log.Error("%v", err)
-			return
-		}
-
-		callback = callbackView
+			b.log.Error("callbackView.match: %v", err)
 
-		if err := callback(ctx, b.bot, update); err != nil {
-			b.log.Error("failed to handle update: %v", err)
-			HandleError(b.bot, update, InternalServerError)
-			return
+			text := "Кнопка устарела, начните заново с /start"
+			if errors.Is(err, ErrMalformedCallback) {
+				text = "Некорректные данные кнопки"
+			}
+			if _, err := bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, text)); err != nil {
+				b.log.Error("failed to answer callback: %v", err)
+			}
+			return nil
 		}
+
+		return callback(withParams(ctx, params), bot, update)
 		// if request on join chat
 	} else if update.ChatJoinRequest != nil {
-		b.log.Info("[%s] %s", update.ChatJoinRequest.From.UserName, update.ChatJoinRequest.InviteLink.InviteLink)
-
+		if b.joinRequestView != nil {
+			return b.joinRequestView(ctx, bot, update)
+		}
+		// if user joined/left a channel
+	} else if update.ChatMember != nil {
+		if b.chatMemberView != nil {
+			return b.chatMemberView(ctx, bot, update)
+		}
 		// if bot update/delete from channel
 	} else if update.MyChatMember != nil {
 
 		if update.MyChatMember.Chat.IsChannel() {
-			b.log.Info("[%s] %s", update.MyChatMember.From.UserName, update.MyChatMember.NewChatMember.Status)
-
 			if update.MyChatMember.NewChatMember.Status == "administrator" {
+				// the rights of the bot changed in a channel it already administers
+				_, err := b.chRepo.GetByChannelTelegramID(ctx, update.MyChatMember.Chat.ID)
+				if err == nil {
+					return nil
+				}
+				if !errors.Is(err, pgx.ErrNoRows) {
+					b.log.Error("update.MyChatMember.Chat: chRepo.GetByChannelTelegramID: %v", err)
+					return nil
+				}
 
 				var link string
 				if update.MyChatMember.Chat.InviteLink == "" {
-					createLink := tgbotapi.CreateChatInviteLinkConfig{
-						ChatConfig: tgbotapi.ChatConfig{
-							ChatID: update.MyChatMember.Chat.ID,
-						},
-						CreatesJoinRequest: true,
-					}
-
-					response, err := b.bot.Request(createLink)
+					inviteLink, err := bot.CreateChatInviteLink(channelLinkConfig(update.MyChatMember.Chat.ID))
 					if err != nil {
 						b.log.Error("update.MyChatMember.Chat: create link error: %v", err)
-						return
-					}
-
-					resultByte, err := response.Result.MarshalJSON()
-					if err != nil {
-						b.log.Error("update.MyChatMember.Chat: response.Result.MarshalJSON: %v", err)
-						return
-					}
-
-					type InviteLink struct {
-						InviteLink string `json:"invite_link"`
+						return nil
 					}
-
-					l := &InviteLink{}
-					err = json.Unmarshal(resultByte, l)
-					if err != nil {
-						b.log.Error("update.MyChatMember.Chat: json.Unmarshal: %v", err)
-						return
-					}
-					link = l.InviteLink
+					link = inviteLink.InviteLink
 				} else {
 					link = update.MyChatMember.Chat.InviteLink
 				}
@@ -222,53 +333,64 @@ func (b *Bot) handlerUpdate(ctx context.Context, update *tgbotapi.Update) {
 					ChannelTelegramId: update.MyChatMember.Chat.ID,
 				}); err != nil {
 					b.log.Error("update.MyChatMember.Chat: chRepo.Create: %v", err)
-					return
+					return nil
 				}
 			}
 
+			// admins can rename channels, the telegram id is what identifies them
 			if update.MyChatMember.NewChatMember.Status == "kicked" || update.MyChatMember.NewChatMember.Status == "left" {
-				if err := b.chRepo.DeleteByName(ctx, update.MyChatMember.Chat.Title); err != nil {
-					b.log.Error("update.MyChatMember.Chat: chRepo.DeleteByName: %v", err)
-					return
+				channel, err := b.chRepo.GetByChannelTelegramID(ctx, update.MyChatMember.Chat.ID)
+				if err != nil {
+					if !errors.Is(err, pgx.ErrNoRows) {
+						b.log.Error("update.MyChatMember.Chat: chRepo.GetByChannelTelegramID: %v", err)
+					}
+					return nil
+				}
+
+				if err := b.chRepo.DeleteByID(ctx, channel.ID); err != nil {
+					b.log.Error("update.MyChatMember.Chat: chRepo.DeleteByID: %v", err)
+					return nil
 				}
 			}
 		}
 
 	}
+
+	return nil
 }
 
 func (b *Bot) isStoreExist(ctx context.Context, update *tgbotapi.Update) bool {
 	userID := update.Message.Chat.ID
-	data, exist := b.store.Read(userID)
+	data, exist, err := b.store.Read(ctx, userID)
+	if err != nil {
+		b.log.Error("isStoreExist: store.Read: %v", err)
+		HandleError(b.bot, update, InternalServerError)
+		return true
+	}
 	if !exist {
 		return false
 	}
 
-	switch s := data.(type) {
-	case store.AdminStore:
-		defer b.store.Delete(userID)
-
-		if s.TypeCommand == store.UserAdminCreate {
-			if err := b.userRepo.UpdateRoleByUsername(ctx, "admin", update.Message.Text); err != nil {
-				b.log.Error("isStoreExist:userRepo.UpdateRoleByUsername: %v", err)
-				HandleError(b.bot, update, InternalServerError)
-				return true
-			}
-			return true
-		}
-		if s.TypeCommand == store.UserAdminDelete {
-			if err := b.userRepo.UpdateRoleByUsername(ctx, "user", update.Message.Text); err != nil {
-				b.log.Error("isStoreExist:userRepo.UpdateRoleByUsername: %v", err)
-				HandleError(b.bot, update, InternalServerError)
-				return true
-			}
-			return true
+	if view, ok := b.stateView[data.Kind()]; ok {
+		if err := view(withState(ctx, data), b.bot, update); err != nil {
+			b.log.Error("isStoreExist: %s: %v", data.Kind(), err)
+			HandleError(b.bot, update, InternalServerError)
 		}
-
-		b.log.Error("isStoreExist: undefind type command")
 		return true
-	default:
-		b.log.Error("isStoreExist: type switching error")
-		return false
 	}
+
+	// the state belongs to a conversation driven by buttons, messages are handled as usual
+	return false
+}
+
+type stateKey struct{}
+
+// State returns the conversation state of the message being handled by a state view.
+func State(ctx context.Context) store.State {
+	state, _ := ctx.Value(stateKey{}).(store.State)
+	return state
+}
+
+func withState(ctx context.Context, state store.State) context.Context {
+	return context.WithValue(ctx, stateKey{}, state)
 }
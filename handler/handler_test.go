@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/membership"
+	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram/fake"
+	"subscriber-check-bot/repo"
+	"testing"
+	"time"
+)
+
+const (
+	testUserID = 100
+	testChatID = 100
+)
+
+// The fakes embed the repo interfaces, a call to a method the test doesn't expect panics.
+
+type fakeUserRepo struct {
+	repo.UserRepo
+	user      *model.User
+	confirmed []int64
+}
+
+func (r *fakeUserRepo) GetUserByID(context.Context, int64) (*model.User, error) {
+	if r.user == nil {
+		return nil, pgx.ErrNoRows
+	}
+	return r.user, nil
+}
+
+func (r *fakeUserRepo) ConfirmReferral(_ context.Context, userID int64) error {
+	r.confirmed = append(r.confirmed, userID)
+	return nil
+}
+
+type fakeCampaignRepo struct {
+	repo.CampaignRepo
+	campaign *model.Campaign
+}
+
+func (r *fakeCampaignRepo) GetByID(context.Context, int) (*model.Campaign, error) {
+	return r.campaign, nil
+}
+
+type fakeLinkRepo struct {
+	repo.InviteLinkRepo
+	links []*model.InviteLink
+}
+
+func (r *fakeLinkRepo) GetActive(_ context.Context, userID int64, channelID int, createsJoinRequest bool) (*model.InviteLink, error) {
+	for _, el := range r.links {
+		if el.UserID == userID && el.ChannelID == channelID && el.CreatesJoinRequest == createsJoinRequest &&
+			el.UsedAt == nil && el.RevokedAt == nil && el.ExpiresAt.After(time.Now()) {
+			return el, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *fakeLinkRepo) GetByLink(_ context.Context, link string) (*model.InviteLink, error) {
+	for _, el := range r.links {
+		if el.InviteLink == link {
+			return el, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *fakeLinkRepo) MarkUsed(_ context.Context, link string, usedAt time.Time) error {
+	for _, el := range r.links {
+		if el.InviteLink == link {
+			el.UsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+func (r *fakeLinkRepo) MarkRevoked(_ context.Context, id int, revokedAt time.Time) error {
+	for _, el := range r.links {
+		if el.ID == id {
+			el.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+func (r *fakeLinkRepo) Create(_ context.Context, link *model.InviteLink) error {
+	link.ID = len(r.links) + 1
+	r.links = append(r.links, link)
+	return nil
+}
+
+type fakeVerifyRepo struct {
+	repo.VerificationRepo
+	verifications []*model.Verification
+}
+
+func (r *fakeVerifyRepo) Create(_ context.Context, verification *model.Verification) error {
+	r.verifications = append(r.verifications, verification)
+	return nil
+}
+
+type fakeFunnelRepo struct {
+	repo.FunnelRepo
+	events []model.FunnelEvent
+}
+
+func (r *fakeFunnelRepo) Create(_ context.Context, _ int64, event model.FunnelEvent, _ int, _ time.Time) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+type fakeSettingRepo struct {
+	repo.SettingRepo
+}
+
+func (r *fakeSettingRepo) GetBool(_ context.Context, _ string, def bool) (bool, error) {
+	return def, nil
+}
+
+type fakeMessageRepo struct {
+	repo.MessageRepo
+}
+
+func (r *fakeMessageRepo) GetByKey(context.Context, string) (*model.Message, error) {
+	return nil, pgx.ErrNoRows
+}
+
+type readyTest struct {
+	bot      *fake.Client
+	campaign *model.Campaign
+	handler  *CallbackHandler
+	store    store.Store
+	users    *fakeUserRepo
+	links    *fakeLinkRepo
+	verifies *fakeVerifyRepo
+	funnel   *fakeFunnelRepo
+}
+
+// newReadyTest sets up a campaign with two required channels leading to the target channel.
+func newReadyTest(captcha bool) *readyTest {
+	log := logger.New()
+	bot := fake.New()
+
+	campaign := &model.Campaign{
+		ID: model.DefaultCampaignID,
+		Channels: []model.Channel{
+			{ID: 1, ChannelTelegramId: -1001, Name: "Первый", URL: "https://t.me/first", IsRequired: true},
+			{ID: 2, ChannelTelegramId: -1002, Name: "Второй", URL: "https://t.me/second", IsRequired: true},
+		},
+		Target: &model.Channel{ID: 3, ChannelTelegramId: -1003, Name: "Главный"},
+	}
+
+	test := &readyTest{
+		bot:      bot,
+		campaign: campaign,
+		store:    store.NewMemoryStore(time.Hour),
+		users:    &fakeUserRepo{},
+		links:    &fakeLinkRepo{},
+		verifies: &fakeVerifyRepo{},
+		funnel:   &fakeFunnelRepo{},
+	}
+	test.handler = &CallbackHandler{Log: log,
+		Store:   test.store,
+		Checker: membership.NewChecker(bot, membership.NewCache(time.Minute), log, 2, membership.FailPolicyFail),
+		Texts:   &Texts{Log: log, MsgRepo: &fakeMessageRepo{}},
+
+		UserRepo:     test.users,
+		LinkRepo:     test.links,
+		CampaignRepo: &fakeCampaignRepo{campaign: campaign},
+		VerifyRepo:   test.verifies,
+		SettingRepo:  &fakeSettingRepo{},
+		FunnelRepo:   test.funnel,
+
+		LinkTTL: 24 * time.Hour,
+
+		CaptchaEnabled:       captcha,
+		CaptchaMaxAttempts:   3,
+		CaptchaBlockDuration: time.Hour,
+	}
+
+	return test
+}
+
+func (r *readyTest) press(t *testing.T) {
+	t.Helper()
+
+	update := &tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   "1",
+		From: &tgbotapi.User{ID: testUserID},
+		Message: &tgbotapi.Message{
+			MessageID: 10,
+			Chat:      &tgbotapi.Chat{ID: testChatID},
+			Text:      "После подписки на все каналы нажмите на кнопку - ГОТОВО",
+		},
+		Data: "ready",
+	}}
+
+	if err := r.handler.Ready()(context.Background(), r.bot, update); err != nil {
+		t.Fatalf("Ready: %v", err)
+	}
+}
+
+func (r *readyTest) subscribe(channelIDs ...int64) {
+	for _, el := range channelIDs {
+		r.bot.SetMember(el, testUserID, "member")
+	}
+}
+
+func (r *readyTest) createdLinks() int {
+	var count int
+	for _, el := range r.bot.Requests() {
+		if _, ok := el.(tgbotapi.CreateChatInviteLinkConfig); ok {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/membership"
+	"subscriber-check-bot/pkg/telegram"
+	"time"
+)
+
+// errAlreadyMember is returned by issueInviteLink for a user who is in the target channel already.
+var errAlreadyMember = errors.New("user is a member of the target channel")
+
+// issueInviteLink returns the valid link already issued to the user for the campaign target channel,
+// or creates a new one expiring after LinkTTL. A member of the target channel gets no link at all, so
+// a joined user can't mint links for others; a user who left gets a new one.
+func (c *CallbackHandler) issueInviteLink(ctx context.Context, bot telegram.Client, userID int64, campaign *model.Campaign) (*model.InviteLink, error) {
+	channel := campaign.Target
+
+	link, err := c.LinkRepo.GetActive(ctx, userID, channel.ID, c.JoinRequest)
+	if err == nil {
+		return link, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	// a failed check doesn't stop the link, a member simply can't use it
+	report := c.Checker.Check(ctx, []model.Channel{*channel}, userID)
+	if report.Results[0].Status == membership.StatusMember {
+		return nil, errAlreadyMember
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(c.LinkTTL)
+
+	createLink := tgbotapi.CreateChatInviteLinkConfig{
+		ChatConfig: tgbotapi.ChatConfig{
+			ChatID: channel.ChannelTelegramId,
+		},
+		ExpireDate: int(expiresAt.Unix()),
+	}
+	// a join-request link can't have a member limit, the request is checked again when it arrives
+	if c.JoinRequest {
+		createLink.CreatesJoinRequest = true
+	} else {
+		createLink.MemberLimit = 1
+	}
+
+	inviteLink, err := bot.CreateChatInviteLink(createLink)
+	if err != nil {
+		return nil, err
+	}
+
+	link = &model.InviteLink{
+		UserID:             userID,
+		ChannelID:          channel.ID,
+		ChannelTelegramID:  channel.ChannelTelegramId,
+		CampaignID:         campaign.ID,
+		InviteLink:         inviteLink.InviteLink,
+		CreatesJoinRequest: c.JoinRequest,
+		CreatedAt:          now,
+		ExpiresAt:          expiresAt,
+	}
+	if err := c.LinkRepo.Create(ctx, link); err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// markLinkUsed records that the issued link was used to join, links we didn't issue are ignored.
+// A single-use link joined by someone other than the user it was issued to was given away: the joiner
+// is removed and the link no longer counts as the owner's one, so the owner can get a new link.
+func (m *MemberHandler) markLinkUsed(ctx context.Context, bot telegram.Client, link *tgbotapi.ChatInviteLink, userID int64) {
+	if link == nil {
+		return
+	}
+
+	issued, err := m.LinkRepo.GetByLink(ctx, link.InviteLink)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			m.Log.Error("markLinkUsed: LinkRepo.GetByLink: %v", err)
+		}
+		return
+	}
+
+	if issued.UserID != userID {
+		m.Log.Info("invite link %d issued to %d was used by %d", issued.ID, issued.UserID, userID)
+
+		// a join request is checked for the user who sent it, that user is allowed in
+		if issued.CreatesJoinRequest {
+			return
+		}
+
+		m.removeJoiner(ctx, bot, issued, userID)
+		return
+	}
+
+	if err := m.LinkRepo.MarkUsed(ctx, link.InviteLink, time.Now()); err != nil {
+		m.Log.Error("markLinkUsed: LinkRepo.MarkUsed: %v", err)
+	}
+}
+
+func (m *MemberHandler) removeJoiner(ctx context.Context, bot telegram.Client, issued *model.InviteLink, userID int64) {
+	member := tgbotapi.ChatMemberConfig{ChatID: issued.ChannelTelegramID, UserID: userID}
+
+	if _, err := bot.Request(tgbotapi.BanChatMemberConfig{ChatMemberConfig: member}); err != nil {
+		m.Log.Error("removeJoiner: ban user %d: %v", userID, err)
+		return
+	}
+	// unban right away, so the user can come back with a link of their own
+	if _, err := bot.Request(tgbotapi.UnbanChatMemberConfig{ChatMemberConfig: member, OnlyIfBanned: true}); err != nil {
+		m.Log.Error("removeJoiner: unban user %d: %v", userID, err)
+	}
+
+	if err := m.LinkRepo.MarkRevoked(ctx, issued.ID, time.Now()); err != nil {
+		m.Log.Error("removeJoiner: LinkRepo.MarkRevoked: %v", err)
+	}
+
+	text := "Эта ссылка была выдана другому пользователю. Чтобы получить свою, отправьте /start"
+	if _, err := bot.Send(tgbotapi.NewMessage(userID, text)); err != nil {
+		m.Log.Error("removeJoiner: failed to notify user %d: %v", userID, err)
+	}
+
+	m.Log.Info("user %d removed for joining through link %d of user %d", userID, issued.ID, issued.UserID)
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strings"
+	"subscriber-check-bot/pkg/membership"
+	"testing"
+	"time"
+)
+
+func TestReadyReusesActiveLink(t *testing.T) {
+	test := newReadyTest(false)
+	test.subscribe(-1001, -1002)
+
+	test.press(t)
+	test.press(t)
+
+	if got := test.createdLinks(); got != 1 {
+		t.Fatalf("created links = %d, want the first link reused", got)
+	}
+	for _, el := range test.bot.Sent() {
+		if msg, ok := el.(tgbotapi.MessageConfig); ok && !strings.Contains(msg.Text, "https://t.me/+fake1") {
+			t.Errorf("sent %q, want the same link twice", msg.Text)
+		}
+	}
+}
+
+func TestReadyRefusesMemberOfTarget(t *testing.T) {
+	test := newReadyTest(false)
+	test.subscribe(-1001, -1002, -1003)
+
+	test.press(t)
+
+	if got := test.createdLinks(); got != 0 {
+		t.Fatalf("created links = %d, want none for a member of the target channel", got)
+	}
+	sent := test.bot.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(sent))
+	}
+	if msg, ok := sent[0].(tgbotapi.MessageConfig); !ok || !strings.Contains(msg.Text, "уже состоите") {
+		t.Errorf("sent %+v, want the already a member message", sent[0])
+	}
+}
+
+func TestJoinThroughLink(t *testing.T) {
+	const strangerID = 200
+
+	tests := []struct {
+		name        string
+		joinerID    int64
+		wantRemoved bool
+	}{
+		{"owner joins", testUserID, false},
+		{"someone else joins", strangerID, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newReadyTest(false)
+			test.subscribe(-1001, -1002)
+			test.press(t)
+			link := test.links.links[0]
+
+			handler := test.memberHandler()
+			handler.Cache = membership.NewCache(time.Minute)
+
+			update := &tgbotapi.Update{ChatMember: &tgbotapi.ChatMemberUpdated{
+				Chat:          tgbotapi.Chat{ID: -1003},
+				OldChatMember: tgbotapi.ChatMember{User: &tgbotapi.User{ID: tt.joinerID}, Status: "left"},
+				NewChatMember: tgbotapi.ChatMember{User: &tgbotapi.User{ID: tt.joinerID}, Status: "member"},
+				InviteLink:    &tgbotapi.ChatInviteLink{InviteLink: link.InviteLink},
+			}}
+			if err := handler.ChatMemberUpdated()(context.Background(), test.bot, update); err != nil {
+				t.Fatalf("ChatMemberUpdated: %v", err)
+			}
+
+			var banned bool
+			for _, el := range test.bot.Requests() {
+				if ban, ok := el.(tgbotapi.BanChatMemberConfig); ok && ban.UserID == tt.joinerID {
+					banned = true
+				}
+			}
+			if banned != tt.wantRemoved {
+				t.Fatalf("joiner removed = %t, want %t", banned, tt.wantRemoved)
+			}
+
+			if tt.wantRemoved {
+				if link.RevokedAt == nil || link.UsedAt != nil {
+					t.Errorf("link used = %v, revoked = %v, want revoked so the owner gets a new one", link.UsedAt, link.RevokedAt)
+				}
+				return
+			}
+			if link.UsedAt == nil {
+				t.Error("link not marked used")
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/membership"
+	"subscriber-check-bot/pkg/telegram"
+	"subscriber-check-bot/repo"
+)
+
+type MemberHandler struct {
+	Log     *logger.Logger
+	Cache   *membership.Cache
+	Checker *membership.Checker
+	Texts   *Texts
+
+	ChRepo       repo.ChannelRepo
+	UserRepo     repo.UserRepo
+	LinkRepo     repo.InviteLinkRepo
+	CampaignRepo repo.CampaignRepo
+
+	// JoinRequest enables approving join requests to campaign target channels for subscribed users.
+	JoinRequest bool
+}
+
+// ChatMemberUpdated drops the cached membership of a user who left or was kicked from a channel.
+func (m *MemberHandler) ChatMemberUpdated() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		member := update.ChatMember.NewChatMember
+		if member.User == nil {
+			return nil
+		}
+
+		if member.HasLeft() || member.WasKicked() {
+			m.Cache.Invalidate(member.User.ID, update.ChatMember.Chat.ID)
+		}
+
+		if member.Status == "member" && update.ChatMember.OldChatMember.Status != "member" {
+			m.markLinkUsed(ctx, bot, update.ChatMember.InviteLink, member.User.ID)
+		}
+
+		return nil
+	}
+}
+
+// ChatJoinRequest approves a join request to a campaign target channel if the user is subscribed to
+// every required channel of the campaign, otherwise declines it and tells the user in DM which
+// channels are missing.
+func (m *MemberHandler) ChatJoinRequest() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		if !m.JoinRequest {
+			return nil
+		}
+
+		request := update.ChatJoinRequest
+
+		channel, err := m.ChRepo.GetByChannelTelegramID(ctx, request.Chat.ID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			m.Log.Error("ChatJoinRequest: ChRepo.GetByChannelTelegramID: %v", err)
+			return err
+		}
+
+		campaign, err := m.requestCampaign(ctx, request, channel)
+		if err != nil {
+			m.Log.Error("ChatJoinRequest: requestCampaign: %v", err)
+			return err
+		}
+		if campaign == nil {
+			return nil
+		}
+
+		report := m.Checker.Check(ctx, campaign.Channels, request.From.ID)
+		if m.Checker.Passed(report) {
+			approve := tgbotapi.ApproveChatJoinRequestConfig{
+				ChatConfig: tgbotapi.ChatConfig{ChatID: request.Chat.ID},
+				UserID:     request.From.ID,
+			}
+			if _, err := bot.Request(approve); err != nil {
+				m.Log.Error("ChatJoinRequest: approve: %v", err)
+				return err
+			}
+
+			m.markLinkUsed(ctx, bot, request.InviteLink, request.From.ID)
+
+			m.Log.Info("join request of %d to %s approved", request.From.ID, channel.Name)
+			return nil
+		}
+
+		decline := tgbotapi.DeclineChatJoinRequest{
+			ChatConfig: tgbotapi.ChatConfig{ChatID: request.Chat.ID},
+			UserID:     request.From.ID,
+		}
+		if _, err := bot.Request(decline); err != nil {
+			m.Log.Error("ChatJoinRequest: decline: %v", err)
+			return err
+		}
+		m.Log.Info("join request of %d to %s declined", request.From.ID, channel.Name)
+
+		return m.sendDeclined(ctx, bot, request.From.ID, campaign, report)
+	}
+}
+
+// requestCampaign returns the campaign the join request to channel is checked against: the campaign of
+// the issued link it came through, then the campaign of the user, then the default campaign and the first
+// campaign targeting channel. It returns nil if channel is not the target of any campaign.
+func (m *MemberHandler) requestCampaign(ctx context.Context, request *tgbotapi.ChatJoinRequest, channel *model.Channel) (*model.Campaign, error) {
+	ids := make([]int, 0, 3)
+
+	if request.InviteLink != nil {
+		link, err := m.LinkRepo.GetByLink(ctx, request.InviteLink.InviteLink)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		if err == nil {
+			ids = append(ids, link.CampaignID)
+		}
+	}
+
+	user, err := m.UserRepo.GetUserByID(ctx, request.From.ID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil && user.CampaignID != nil {
+		ids = append(ids, *user.CampaignID)
+	}
+
+	ids = append(ids, model.DefaultCampaignID)
+
+	for _, id := range ids {
+		campaign, err := m.CampaignRepo.GetByID(ctx, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if campaign.Target != nil && campaign.Target.ID == channel.ID {
+			return campaign, nil
+		}
+	}
+
+	campaigns, err := m.CampaignRepo.GetByTargetChannel(ctx, channel.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(campaigns) == 0 {
+		return nil, nil
+	}
+
+	return m.CampaignRepo.GetByID(ctx, campaigns[0].ID)
+}
+
+func (m *MemberHandler) sendDeclined(ctx context.Context, bot telegram.Client, userID int64, campaign *model.Campaign, report membership.Report) error {
+	channel := campaign.Target
+
+	missing := report.Missing()
+
+	text := m.Texts.Render(ctx, TextUnchecked, "{channel}", channel.Name)
+	if len(missing) != 0 {
+		text = m.Texts.Render(ctx, TextDeclined, "{channel}", channel.Name, "{channels}", channelList(missing))
+	}
+
+	markup, err := createChannelMarkup(missing, "user")
+	if err != nil {
+		m.Log.Error("sendDeclined: createChannelMarkup: %v", err)
+		return err
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, readyRow(campaign.ID))
+
+	if _, err := bot.Send(telegram.NewMessage(userID, text, markup.InlineKeyboard...)); err != nil {
+		m.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/repo"
+	"testing"
+)
+
+type fakeChannelRepo struct {
+	repo.ChannelRepo
+	channels []model.Channel
+}
+
+func (r *fakeChannelRepo) GetByChannelTelegramID(_ context.Context, channelTelegramID int64) (*model.Channel, error) {
+	for _, el := range r.channels {
+		if el.ChannelTelegramId == channelTelegramID {
+			return &el, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+// memberHandler shares the bot, the campaign and the repos of the ready test.
+func (r *readyTest) memberHandler() *MemberHandler {
+	return &MemberHandler{Log: r.handler.Log,
+		Checker: r.handler.Checker,
+		Texts:   r.handler.Texts,
+
+		ChRepo:       &fakeChannelRepo{channels: []model.Channel{*r.campaign.Target}},
+		UserRepo:     r.users,
+		LinkRepo:     r.links,
+		CampaignRepo: r.handler.CampaignRepo,
+
+		JoinRequest: true,
+	}
+}
+
+func TestReadyIssuesJoinRequestLink(t *testing.T) {
+	test := newReadyTest(false)
+	test.handler.JoinRequest = true
+	test.subscribe(-1001, -1002)
+
+	test.press(t)
+
+	var created []tgbotapi.CreateChatInviteLinkConfig
+	for _, el := range test.bot.Requests() {
+		if link, ok := el.(tgbotapi.CreateChatInviteLinkConfig); ok {
+			created = append(created, link)
+		}
+	}
+	if len(created) != 1 {
+		t.Fatalf("created links = %d, want 1", len(created))
+	}
+	if !created[0].CreatesJoinRequest || created[0].MemberLimit != 0 || created[0].ChatID != -1003 {
+		t.Errorf("link config = %+v, want a join-request link to the target channel without a member limit", created[0])
+	}
+	if len(test.links.links) != 1 || !test.links.links[0].CreatesJoinRequest {
+		t.Errorf("stored links = %+v, want one join-request link", test.links.links)
+	}
+}
+
+func TestChatJoinRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		subscribed  []int64
+		wantApprove bool
+	}{
+		{"subscribed", []int64{-1001, -1002}, true},
+		{"missing a channel", []int64{-1001}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newReadyTest(false)
+			test.subscribe(tt.subscribed...)
+
+			update := &tgbotapi.Update{ChatJoinRequest: &tgbotapi.ChatJoinRequest{
+				Chat: tgbotapi.Chat{ID: -1003},
+				From: tgbotapi.User{ID: testUserID},
+			}}
+			if err := test.memberHandler().ChatJoinRequest()(context.Background(), test.bot, update); err != nil {
+				t.Fatalf("ChatJoinRequest: %v", err)
+			}
+
+			var approved, declined bool
+			for _, el := range test.bot.Requests() {
+				switch el.(type) {
+				case tgbotapi.ApproveChatJoinRequestConfig:
+					approved = true
+				case tgbotapi.DeclineChatJoinRequest:
+					declined = true
+				}
+			}
+			if approved != tt.wantApprove || declined == tt.wantApprove {
+				t.Fatalf("approved = %t, declined = %t, want approved %t", approved, declined, tt.wantApprove)
+			}
+
+			sent := test.bot.Sent()
+			if tt.wantApprove {
+				if len(sent) != 0 {
+					t.Errorf("sent %d messages to an approved user, want none", len(sent))
+				}
+				return
+			}
+			if len(sent) != 1 {
+				t.Fatalf("sent %d messages, want the decline DM", len(sent))
+			}
+			msg, ok := sent[0].(tgbotapi.MessageConfig)
+			if !ok || msg.ChatID != testUserID || !strings.Contains(msg.Text, "Второй") {
+				t.Errorf("decline DM = %+v, want the missing channel sent to the user", sent[0])
+			}
+		})
+	}
+}
@@ -3,25 +3,201 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/jackc/pgx/v5"
+	"runtime/debug"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/telegram"
 	"subscriber-check-bot/repo"
+	"time"
 )
 
-func AdminMiddleware(service repo.UserRepo, next ViewFunc) ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
-		user, err := service.GetUserByID(ctx, update.FromChat().ID)
-		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil
+// Middleware wraps a ViewFunc with behavior shared by several views.
+type Middleware func(next ViewFunc) ViewFunc
+
+// Chain wraps view with middlewares, the first middleware is the outermost.
+func Chain(view ViewFunc, middlewares ...Middleware) ViewFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		view = middlewares[i](view)
+	}
+	return view
+}
+
+// Group registers views sharing the same middlewares.
+type Group struct {
+	bot         *Bot
+	middlewares []Middleware
+}
+
+func (g *Group) Use(middlewares ...Middleware) {
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+// Group returns a nested group, its views are wrapped with the middlewares of g first.
+func (g *Group) Group(middlewares ...Middleware) *Group {
+	return &Group{bot: g.bot, middlewares: g.with(middlewares)}
+}
+
+func (g *Group) RegisterCommandView(cmd string, view ViewFunc, middlewares ...Middleware) {
+	g.bot.RegisterCommandView(cmd, view, g.with(middlewares)...)
+}
+
+func (g *Group) RegisterCommandCallback(pattern string, view ViewFunc, middlewares ...Middleware) {
+	g.bot.RegisterCommandCallback(pattern, view, g.with(middlewares)...)
+}
+
+func (g *Group) RegisterStateView(kind string, view ViewFunc, middlewares ...Middleware) {
+	g.bot.RegisterStateView(kind, view, g.with(middlewares)...)
+}
+
+func (g *Group) with(middlewares []Middleware) []Middleware {
+	all := make([]Middleware, 0, len(g.middlewares)+len(middlewares))
+	all = append(all, g.middlewares...)
+	return append(all, middlewares...)
+}
+
+// Recovery turns a panic in next into an error.
+func Recovery(log *logger.Logger) Middleware {
+	return func(next ViewFunc) ViewFunc {
+		return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					log.Error("panic recovered: %v, %s", p, string(debug.Stack()))
+					err = fmt.Errorf("panic recovered: %v", p)
+				}
+			}()
+
+			return next(ctx, bot, update)
+		}
+	}
+}
+
+// Logging logs who sent the update, what it was and how long it took.
+func Logging(log *logger.Logger) Middleware {
+	return func(next ViewFunc) ViewFunc {
+		return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+			start := time.Now()
+
+			switch {
+			case update.Message != nil:
+				log.Info("[%s] %s", update.Message.From.UserName, update.Message.Text)
+			case update.CallbackQuery != nil:
+				log.Info("[%s] %s", update.CallbackQuery.From.UserName, update.CallbackData())
+			case update.ChatJoinRequest != nil:
+				log.Info("[%s] join request to %s", update.ChatJoinRequest.From.UserName, update.ChatJoinRequest.Chat.Title)
+			case update.MyChatMember != nil:
+				log.Info("[%s] %s", update.MyChatMember.From.UserName, update.MyChatMember.NewChatMember.Status)
+			}
+
+			err := next(ctx, bot, update)
+			if err != nil {
+				log.Error("update %d handled in %s: %v", update.UpdateID, time.Since(start), err)
 			}
+
 			return err
 		}
+	}
+}
+
+// Timeout limits the time next may spend on an update.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next ViewFunc) ViewFunc {
+		return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			return next(ctx, bot, update)
+		}
+	}
+}
+
+// UserRegistration creates the user on the first message they send. A /start ref_<id> payload
+// stores the referrer if such a user exists.
+func UserRegistration(service repo.UserRepo) Middleware {
+	return func(next ViewFunc) ViewFunc {
+		return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+			if update.Message == nil || update.Message.From == nil {
+				return next(ctx, bot, update)
+			}
+
+			isUserExist, err := service.IsUserExistByUserID(ctx, update.Message.From.ID)
+			if err != nil {
+				return fmt.Errorf("userRepo.IsUserExistByUserID: %w", err)
+			}
+			if !isUserExist {
+				user := &model.User{
+					ID:         update.Message.From.ID,
+					UsernameTg: update.Message.From.UserName,
+					CreatedAt:  time.Now(),
+					Role:       model.RoleUser,
+				}
+
+				if id, ok := referrerID(update); ok {
+					isReferrerExist, err := service.IsUserExistByUserID(ctx, id)
+					if err != nil {
+						return fmt.Errorf("userRepo.IsUserExistByUserID: %w", err)
+					}
+					if isReferrerExist {
+						user.ReferrerID = &id
+					}
+				}
+
+				if err := service.CreateUser(ctx, user); err != nil {
+					return fmt.Errorf("userRepo.CreateUser: failed to create user: %w", err)
+				}
+			}
 
-		if user.Role == "admin" || user.Role == "superAdmin" {
 			return next(ctx, bot, update)
 		}
+	}
+}
 
-		return errors.New("user not admin")
+// Idempotency lets every update through once: a re-delivered update with a known id is skipped.
+// If the check itself fails the update is handled anyway.
+func Idempotency(service repo.UpdateRepo, log *logger.Logger) Middleware {
+	return func(next ViewFunc) ViewFunc {
+		return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+			isNew, err := service.MarkProcessed(ctx, update.UpdateID)
+			if err != nil {
+				log.Error("Idempotency: UpdateRepo.MarkProcessed: %v", err)
+				return next(ctx, bot, update)
+			}
+			if !isNew {
+				log.Info("update %d already processed, skipped", update.UpdateID)
+				return nil
+			}
+
+			return next(ctx, bot, update)
+		}
 	}
 }
+
+// RequireRole lets the update through only if the sender has one of roles.
+func RequireRole(service repo.UserRepo, roles ...string) Middleware {
+	return func(next ViewFunc) ViewFunc {
+		return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+			user, err := service.GetUserByID(ctx, update.FromChat().ID)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return nil
+				}
+				return err
+			}
+
+			for _, role := range roles {
+				if user.Role == role {
+					return next(ctx, bot, update)
+				}
+			}
+
+			return fmt.Errorf("user %d with role %q is not allowed", user.ID, user.Role)
+		}
+	}
+}
+
+// Admin is RequireRole for admins and superAdmins.
+func Admin(service repo.UserRepo) Middleware {
+	return RequireRole(service, model.RoleAdmin, model.RoleSuperAdmin)
+}
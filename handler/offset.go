@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	processedUpdateTTL     = 24 * time.Hour
+	processedCleanupPeriod = time.Hour
+	offsetSaveTimeout      = 5 * time.Second
+)
+
+// offsetTracker computes the getUpdates offset that is safe to persist: updates are handled
+// concurrently, so the offset only moves past an update once every earlier one is done.
+type offsetTracker struct {
+	mu        sync.Mutex
+	pending   map[int]struct{}
+	next      int
+	committed int
+}
+
+func newOffsetTracker(offset int) *offsetTracker {
+	return &offsetTracker{
+		pending:   make(map[int]struct{}),
+		next:      offset,
+		committed: offset,
+	}
+}
+
+func (t *offsetTracker) received(updateID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[updateID] = struct{}{}
+	if updateID >= t.next {
+		t.next = updateID + 1
+	}
+}
+
+// done marks updateID handled and returns the new offset if it moved forward.
+func (t *offsetTracker) done(updateID int) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pending, updateID)
+
+	offset := t.next
+	for id := range t.pending {
+		if id < offset {
+			offset = id
+		}
+	}
+
+	if offset <= t.committed {
+		return 0, false
+	}
+	t.committed = offset
+	return offset, true
+}
+
+func (b *Bot) saveOffset(offset int) {
+	ctx, cancel := context.WithTimeout(context.Background(), offsetSaveTimeout)
+	defer cancel()
+
+	if err := b.updateRepo.SaveOffset(ctx, offset); err != nil {
+		b.log.Error("saveOffset: updateRepo.SaveOffset: %v", err)
+	}
+}
+
+// cleanupProcessed forgets processed update ids old enough to never be delivered again.
+func (b *Bot) cleanupProcessed(ctx context.Context) {
+	ticker := time.NewTicker(processedCleanupPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := b.updateRepo.DeleteProcessedBefore(ctx, time.Now().Add(-processedUpdateTTL)); err != nil {
+				b.log.Error("cleanupProcessed: updateRepo.DeleteProcessedBefore: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/telegram"
+	"subscriber-check-bot/repo"
+	"testing"
+)
+
+func TestOffsetTrackerMovesOverContiguousPrefix(t *testing.T) {
+	type step struct {
+		done       int
+		wantOffset int
+		wantMoved  bool
+	}
+
+	tests := []struct {
+		name     string
+		offset   int
+		received []int
+		steps    []step
+	}{
+		{
+			name:     "in order",
+			offset:   10,
+			received: []int{10, 11, 12},
+			steps:    []step{{10, 11, true}, {11, 12, true}, {12, 13, true}},
+		},
+		{
+			name:     "last done first",
+			offset:   10,
+			received: []int{10, 11, 12},
+			steps:    []step{{12, 0, false}, {11, 0, false}, {10, 13, true}},
+		},
+		{
+			name:     "middle done first",
+			offset:   10,
+			received: []int{10, 11, 12, 13},
+			steps:    []step{{11, 0, false}, {10, 12, true}, {13, 0, false}, {12, 14, true}},
+		},
+		{
+			name:     "gap in update ids",
+			offset:   10,
+			received: []int{10, 15, 20},
+			steps:    []step{{15, 0, false}, {10, 20, true}, {20, 21, true}},
+		},
+		{
+			name:     "starting from zero",
+			offset:   0,
+			received: []int{500, 501},
+			steps:    []step{{501, 500, true}, {500, 502, true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newOffsetTracker(tt.offset)
+			for _, el := range tt.received {
+				tracker.received(el)
+			}
+
+			for _, el := range tt.steps {
+				offset, moved := tracker.done(el.done)
+				if moved != el.wantMoved || offset != el.wantOffset {
+					t.Fatalf("done(%d) = %d, %t, want %d, %t", el.done, offset, moved, el.wantOffset, el.wantMoved)
+				}
+			}
+		})
+	}
+}
+
+type fakeUpdateRepo struct {
+	repo.UpdateRepo
+	processed map[int]bool
+	err       error
+}
+
+func (r *fakeUpdateRepo) MarkProcessed(_ context.Context, updateID int) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	if r.processed[updateID] {
+		return false, nil
+	}
+	r.processed[updateID] = true
+	return true, nil
+}
+
+func TestIdempotencySkipsProcessedUpdates(t *testing.T) {
+	tests := []struct {
+		name      string
+		updates   []int
+		err       error
+		wantCalls int
+	}{
+		{"new updates", []int{1, 2, 3}, nil, 3},
+		{"re-delivered updates", []int{1, 2, 1, 2, 3}, nil, 3},
+		{"failing check lets updates through", []int{1, 1}, errors.New("connection refused"), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			view := Idempotency(&fakeUpdateRepo{processed: make(map[int]bool), err: tt.err}, logger.New())(
+				func(context.Context, telegram.Client, *tgbotapi.Update) error {
+					calls++
+					return nil
+				})
+
+			for _, el := range tt.updates {
+				if err := view(context.Background(), nil, &tgbotapi.Update{UpdateID: el}); err != nil {
+					t.Fatalf("update %d: %v", el, err)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Fatalf("view called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strconv"
+	"strings"
+	"subscriber-check-bot/pkg/telegram"
+)
+
+const (
+	referralPayloadPrefix = "ref_"
+	topReferrersLimit     = 10
+)
+
+// referrerID returns the referrer from the /start ref_<id> payload of update.
+func referrerID(update *tgbotapi.Update) (int64, bool) {
+	if update.Message == nil || update.Message.Command() != "start" {
+		return 0, false
+	}
+
+	payload, ok := strings.CutPrefix(update.Message.CommandArguments(), referralPayloadPrefix)
+	if !ok {
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil || id == update.Message.From.ID {
+		return 0, false
+	}
+
+	return id, true
+}
+
+func referralLink(botName string, userID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", botName, referralPayloadPrefix, userID)
+}
+
+// GetReferral sends the user their referral link and the number of referrals that passed the check.
+func (v *ViewHandler) GetReferral() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		count, err := v.UserRepo.CountReferrals(ctx, update.Message.From.ID)
+		if err != nil {
+			v.Log.Error("GetReferral: UserRepo.CountReferrals: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
+			return nil
+		}
+
+		text := "Ваша реферальная ссылка:\n" + referralLink(v.BotName, update.Message.From.ID) +
+			"\n\nПриглашено пользователей: " + strconv.Itoa(count) +
+			"\nПриглашение засчитывается, когда пользователь подпишется на все каналы"
+
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+		msg.DisableWebPagePreview = true
+		if _, err := bot.Send(msg); err != nil {
+			v.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (c *CallbackHandler) AdminTopReferrers() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		referrers, err := c.UserRepo.GetTopReferrers(ctx, topReferrersLimit)
+		if err != nil {
+			c.Log.Error("AdminTopReferrers: UserRepo.GetTopReferrers: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		var text strings.Builder
+		if len(referrers) == 0 {
+			text.WriteString("Приглашённых пользователей пока нет")
+		} else {
+			text.WriteString("Топ рефереров:\n")
+		}
+		for i, el := range referrers {
+			name := strconv.FormatInt(el.User.ID, 10)
+			if el.User.UsernameTg != "" {
+				name = "@" + el.User.UsernameTg
+			}
+			text.WriteString(fmt.Sprintf("%d. %s — %d\n", i+1, name, el.Referrals))
+		}
+
+		msg := tgbotapi.NewEditMessageText(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID, text.String())
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
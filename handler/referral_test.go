@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"testing"
+)
+
+func TestReadyConfirmsReferral(t *testing.T) {
+	tests := []struct {
+		name          string
+		subscribed    []int64
+		linkErr       error
+		wantConfirmed bool
+	}{
+		{"link issued", []int64{-1001, -1002}, nil, true},
+		{"not subscribed", []int64{-1001}, nil, false},
+		{"link not created", []int64{-1001, -1002}, errors.New("Bad Gateway"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newReadyTest(false)
+			test.subscribe(tt.subscribed...)
+			if tt.linkErr != nil {
+				test.bot.FailOn("CreateChatInviteLink", tt.linkErr)
+			}
+
+			update := &tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+				ID:      "1",
+				From:    &tgbotapi.User{ID: testUserID},
+				Message: &tgbotapi.Message{MessageID: 10, Chat: &tgbotapi.Chat{ID: testChatID}, Text: "ГОТОВО"},
+				Data:    "ready",
+			}}
+			err := test.handler.Ready()(context.Background(), test.bot, update)
+			if (err != nil) != (tt.linkErr != nil) {
+				t.Fatalf("Ready = %v, want error %t", err, tt.linkErr != nil)
+			}
+
+			confirmed := len(test.users.confirmed) == 1 && test.users.confirmed[0] == testUserID
+			if confirmed != tt.wantConfirmed || len(test.users.confirmed) > 1 {
+				t.Fatalf("confirmed referrals = %v, want confirmed %t", test.users.confirmed, tt.wantConfirmed)
+			}
+		})
+	}
+}
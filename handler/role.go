@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strconv"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
+)
+
+// AdminRoleInput is the state view receiving the user whose admin role is granted or revoked: an @username,
+// a numeric ID or a forwarded message of the user.
+func (c *CallbackHandler) AdminRoleInput() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		state, ok := State(ctx).(store.AdminStore)
+		if !ok {
+			return nil
+		}
+
+		role := model.RoleAdmin
+		if state.TypeCommand == store.UserAdminDelete {
+			role = model.RoleUser
+		}
+
+		target, err := c.findUser(ctx, update.Message)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				HandleError(bot, update, "Пользователь не найден, он должен хотя бы раз запустить бота. "+
+					"Отправьте другой никнейм, ID или /cancel")
+				return nil
+			}
+			if errors.Is(err, errHiddenForward) {
+				HandleError(bot, update, "Пользователь скрыл свой аккаунт в пересланных сообщениях, отправьте его никнейм или ID")
+				return nil
+			}
+			if errors.Is(err, errAmbiguousUsername) {
+				HandleError(bot, update, "Этот никнейм есть у нескольких пользователей, отправьте ID или перешлите сообщение пользователя")
+				return nil
+			}
+			c.Log.Error("AdminRoleInput: findUser: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		text, err := c.changeRole(ctx, update.Message.From.ID, target, role)
+		if err != nil {
+			c.Log.Error("AdminRoleInput: changeRole: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		if err := c.Store.Delete(ctx, update.Message.Chat.ID); err != nil {
+			c.Log.Error("AdminRoleInput: Store.Delete: %v", err)
+		}
+
+		if _, err := bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, text)); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+var (
+	errHiddenForward     = errors.New("forwarded message hides the sender")
+	errAmbiguousUsername = errors.New("username matches several users")
+)
+
+// findUser resolves the user an admin points to with a forwarded message, a numeric ID or an @username.
+func (c *CallbackHandler) findUser(ctx context.Context, msg *tgbotapi.Message) (*model.User, error) {
+	if msg.ForwardFrom != nil {
+		return c.UserRepo.GetUserByID(ctx, msg.ForwardFrom.ID)
+	}
+	if msg.ForwardSenderName != "" {
+		return nil, errHiddenForward
+	}
+
+	input := strings.TrimSpace(msg.Text)
+	if id, err := strconv.ParseInt(input, 10, 64); err == nil {
+		return c.UserRepo.GetUserByID(ctx, id)
+	}
+
+	username := strings.TrimPrefix(input, "@")
+	if username == "" || strings.ContainsAny(username, " \n") {
+		return nil, pgx.ErrNoRows
+	}
+	users, err := c.UserRepo.GetUsersByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	switch len(users) {
+	case 0:
+		return nil, pgx.ErrNoRows
+	case 1:
+		return &users[0], nil
+	default:
+		return nil, errAmbiguousUsername
+	}
+}
+
+// changeRole gives role to the target and returns the outcome for the acting admin. Only a superAdmin
+// can change the role of a superAdmin, only by revoking it, and the last superAdmin keeps the role.
+func (c *CallbackHandler) changeRole(ctx context.Context, actorID int64, target *model.User, role string) (string, error) {
+	name := userName(target)
+
+	if target.Role == role {
+		if role == model.RoleUser {
+			return name + " и так не администратор", nil
+		}
+		return name + " уже администратор", nil
+	}
+
+	// granting admin to a superAdmin would silently demote them
+	if target.Role == model.RoleSuperAdmin && role != model.RoleUser {
+		return name + " — суперадминистратор, у него уже есть права администратора", nil
+	}
+
+	if target.Role == model.RoleSuperAdmin {
+		actor, err := c.UserRepo.GetUserByID(ctx, actorID)
+		if err != nil {
+			return "", fmt.Errorf("UserRepo.GetUserByID: %w", err)
+		}
+		if actor.Role != model.RoleSuperAdmin {
+			return "Роль суперадминистратора " + name + " может изменить только суперадминистратор", nil
+		}
+	}
+
+	changed, err := c.UserRepo.UpdateRoleByID(ctx, role, target.ID)
+	if err != nil {
+		return "", fmt.Errorf("UserRepo.UpdateRoleByID: %w", err)
+	}
+	if !changed {
+		if target.Role == model.RoleSuperAdmin {
+			return name + " — последний суперадминистратор, его роль нельзя изменить", nil
+		}
+		return "Роль не изменена: пользователь " + name + " не найден", nil
+	}
+	c.Log.Info("role of user %d changed from %s to %s by %d", target.ID, target.Role, role, actorID)
+
+	if role == model.RoleUser {
+		return "У пользователя " + name + " отозвана роль администратора", nil
+	}
+	return name + " назначен администратором", nil
+}
+
+// userName is @username with the ID, or just the ID for users without a username.
+func userName(user *model.User) string {
+	if user.UsernameTg == "" {
+		return strconv.FormatInt(user.ID, 10)
+	}
+	return fmt.Sprintf("@%s (%d)", user.UsernameTg, user.ID)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/repo"
+	"testing"
+)
+
+type fakeRoleRepo struct {
+	repo.UserRepo
+	users map[int64]*model.User
+}
+
+func (r *fakeRoleRepo) GetUserByID(_ context.Context, id int64) (*model.User, error) {
+	return r.users[id], nil
+}
+
+func (r *fakeRoleRepo) UpdateRoleByID(_ context.Context, role string, id int64) (bool, error) {
+	r.users[id].Role = role
+	return true, nil
+}
+
+func TestChangeRole(t *testing.T) {
+	const actorID, targetID = 1, 2
+
+	tests := []struct {
+		name       string
+		actorRole  string
+		targetRole string
+		role       string
+		wantRole   string
+		wantText   string
+	}{
+		{"grant admin", model.RoleAdmin, model.RoleUser, model.RoleAdmin, model.RoleAdmin, "назначен администратором"},
+		{"revoke admin", model.RoleAdmin, model.RoleAdmin, model.RoleUser, model.RoleUser, "отозвана"},
+		{"grant admin to an admin", model.RoleAdmin, model.RoleAdmin, model.RoleAdmin, model.RoleAdmin, "уже администратор"},
+		{"grant admin to a superAdmin", model.RoleSuperAdmin, model.RoleSuperAdmin, model.RoleAdmin, model.RoleSuperAdmin, "суперадминистратор"},
+		{"admin revokes a superAdmin", model.RoleAdmin, model.RoleSuperAdmin, model.RoleUser, model.RoleSuperAdmin, "только суперадминистратор"},
+		{"superAdmin revokes a superAdmin", model.RoleSuperAdmin, model.RoleSuperAdmin, model.RoleUser, model.RoleUser, "отозвана"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeRoleRepo{users: map[int64]*model.User{
+				actorID:  {ID: actorID, Role: tt.actorRole},
+				targetID: {ID: targetID, UsernameTg: "target", Role: tt.targetRole},
+			}}
+			handler := &CallbackHandler{Log: logger.New(), UserRepo: users}
+
+			target := *users.users[targetID]
+			text, err := handler.changeRole(context.Background(), actorID, &target, tt.role)
+			if err != nil {
+				t.Fatalf("changeRole: %v", err)
+			}
+			if !strings.Contains(text, tt.wantText) {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if got := users.users[targetID].Role; got != tt.wantRole {
+				t.Fatalf("role = %s, want %s", got, tt.wantRole)
+			}
+		})
+	}
+}
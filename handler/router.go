@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCallback   = errors.New("unknown callback")
+	ErrMalformedCallback = errors.New("malformed callback")
+)
+
+const callbackSeparator = "/"
+
+type paramsKey struct{}
+
+// Params holds the values parsed from callback data by the route pattern.
+type Params map[string]string
+
+// CallbackParams returns the params of the callback being handled.
+func CallbackParams(ctx context.Context) Params {
+	params, _ := ctx.Value(paramsKey{}).(Params)
+	return params
+}
+
+func withParams(ctx context.Context, params Params) context.Context {
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+func (p Params) String(name string) string {
+	return p[name]
+}
+
+// Int returns the param as int. Params declared as int are validated before the view runs.
+func (p Params) Int(name string) int {
+	value, _ := strconv.Atoi(p[name])
+	return value
+}
+
+func (p Params) Int64(name string) int64 {
+	value, _ := strconv.ParseInt(p[name], 10, 64)
+	return value
+}
+
+type segment struct {
+	literal string
+	param   string
+	kind    string
+}
+
+type route struct {
+	pattern  string
+	segments []segment
+	view     ViewFunc
+}
+
+// router matches callback data such as "channel_set/5" against patterns such as "channel_set/{id:int}".
+// Supported param kinds are string (default), int and int64.
+type router struct {
+	routes []route
+}
+
+func parsePattern(pattern string) ([]segment, error) {
+	parts := strings.Split(pattern, callbackSeparator)
+	segments := make([]segment, 0, len(parts))
+
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") {
+			if part == "" || strings.ContainsAny(part, "{}") {
+				return nil, fmt.Errorf("pattern %q: invalid segment %q", pattern, part)
+			}
+			segments = append(segments, segment{literal: part})
+			continue
+		}
+
+		if i == 0 || !strings.HasSuffix(part, "}") {
+			return nil, fmt.Errorf("pattern %q: invalid param %q", pattern, part)
+		}
+
+		name, kind, _ := strings.Cut(strings.Trim(part, "{}"), ":")
+		if kind == "" {
+			kind = "string"
+		}
+
+		switch kind {
+		case "string", "int", "int64":
+		default:
+			return nil, fmt.Errorf("pattern %q: unknown param kind %q", pattern, kind)
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("pattern %q: empty param name", pattern)
+		}
+
+		segments = append(segments, segment{param: name, kind: kind})
+	}
+
+	return segments, nil
+}
+
+func (r *router) handle(pattern string, view ViewFunc) {
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, el := range r.routes {
+		if el.pattern == pattern {
+			panic(fmt.Sprintf("pattern %q registered twice", pattern))
+		}
+	}
+
+	r.routes = append(r.routes, route{
+		pattern:  pattern,
+		segments: segments,
+		view:     view,
+	})
+}
+
+// match finds the view for data. It returns ErrMalformedCallback when a route with the same
+// name exists but the params don't fit it, and ErrUnknownCallback when there is no such route.
+func (r *router) match(data string) (ViewFunc, Params, error) {
+	parts := strings.Split(data, callbackSeparator)
+
+	claimed := false
+	for _, el := range r.routes {
+		if el.segments[0].literal != parts[0] {
+			continue
+		}
+		claimed = true
+
+		params, ok := el.parse(parts)
+		if ok {
+			return el.view, params, nil
+		}
+	}
+
+	if claimed {
+		return nil, nil, fmt.Errorf("%w: %q", ErrMalformedCallback, data)
+	}
+	return nil, nil, fmt.Errorf("%w: %q", ErrUnknownCallback, data)
+}
+
+func (r route) parse(parts []string) (Params, bool) {
+	if len(parts) != len(r.segments) {
+		return nil, false
+	}
+
+	params := make(Params)
+	for i, el := range r.segments {
+		if el.literal != "" {
+			if el.literal != parts[i] {
+				return nil, false
+			}
+			continue
+		}
+
+		switch el.kind {
+		case "int":
+			if _, err := strconv.Atoi(parts[i]); err != nil {
+				return nil, false
+			}
+		case "int64":
+			if _, err := strconv.ParseInt(parts[i], 10, 64); err != nil {
+				return nil, false
+			}
+		default:
+			if parts[i] == "" {
+				return nil, false
+			}
+		}
+
+		params[el.param] = parts[i]
+	}
+
+	return params, true
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"reflect"
+	"subscriber-check-bot/pkg/telegram"
+	"testing"
+)
+
+func TestRouterMatch(t *testing.T) {
+	var r router
+	var called string
+	for _, pattern := range []string{
+		"ready",
+		"ready/{campaign:int}",
+		"channel_set/{id:int}",
+		"text/{key}",
+		"captcha/{campaign:int}/{answer:int}",
+		"user/{id:int64}",
+	} {
+		r.handle(pattern, func(context.Context, telegram.Client, *tgbotapi.Update) error {
+			called = pattern
+			return nil
+		})
+	}
+
+	tests := []struct {
+		data        string
+		wantPattern string
+		wantParams  Params
+		wantErr     error
+	}{
+		{"ready", "ready", Params{}, nil},
+		{"ready/3", "ready/{campaign:int}", Params{"campaign": "3"}, nil},
+		{"channel_set/-5", "channel_set/{id:int}", Params{"id": "-5"}, nil},
+		{"text/start", "text/{key}", Params{"key": "start"}, nil},
+		{"captcha/2/17", "captcha/{campaign:int}/{answer:int}", Params{"campaign": "2", "answer": "17"}, nil},
+		{"user/9007199254740993", "user/{id:int64}", Params{"id": "9007199254740993"}, nil},
+		{"channel_set/abc", "", nil, ErrMalformedCallback},
+		{"channel_set", "", nil, ErrMalformedCallback},
+		{"text/", "", nil, ErrMalformedCallback},
+		{"captcha/2", "", nil, ErrMalformedCallback},
+		{"ready/1/2", "", nil, ErrMalformedCallback},
+		{"unknown/1", "", nil, ErrUnknownCallback},
+		{"", "", nil, ErrUnknownCallback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			called = ""
+			view, params, err := r.match(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("match error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			_ = view(context.Background(), nil, nil)
+			if called != tt.wantPattern {
+				t.Errorf("matched %q, want %q", called, tt.wantPattern)
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("params = %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestParsePatternRejectsInvalid(t *testing.T) {
+	for _, pattern := range []string{
+		"",
+		"{id:int}",
+		"channel//x",
+		"channel/{id:float}",
+		"channel/{:int}",
+		"channel/{id",
+		"chan{nel",
+	} {
+		if _, err := parsePattern(pattern); err == nil {
+			t.Errorf("parsePattern(%q) succeeded, want error", pattern)
+		}
+	}
+}
+
+func TestParamsInt(t *testing.T) {
+	params := Params{"id": "42", "big": "9007199254740993", "name": "x"}
+
+	if got := params.Int("id"); got != 42 {
+		t.Errorf("Int(id) = %d, want 42", got)
+	}
+	if got := params.Int64("big"); got != 9007199254740993 {
+		t.Errorf("Int64(big) = %d, want 9007199254740993", got)
+	}
+	if got := params.Int("name"); got != 0 {
+		t.Errorf("Int(name) = %d, want 0", got)
+	}
+	if got := CallbackParams(withParams(context.Background(), params)).String("name"); got != "x" {
+		t.Errorf("CallbackParams String(name) = %q, want x", got)
+	}
+}
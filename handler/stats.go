@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/telegram"
+	"subscriber-check-bot/repo"
+	"time"
+)
+
+// statsNewUsersDays is how many days the new users table covers.
+const statsNewUsersDays = 14
+
+// recordFunnel stores the step the user reached. A failure to store it is only logged, the user still
+// gets the answer.
+func recordFunnel(ctx context.Context, log *logger.Logger, funnelRepo repo.FunnelRepo, userID int64,
+	event model.FunnelEvent, campaignID int) {
+	if err := funnelRepo.Create(ctx, userID, event, campaignID, time.Now()); err != nil {
+		log.Error("recordFunnel: FunnelRepo.Create %s: %v", event, err)
+	}
+}
+
+// AdminStats shows the funnel from /start to the invite link for several periods and the new users per day.
+func (c *CallbackHandler) AdminStats() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+		periods := []struct {
+			title string
+			since time.Time
+		}{
+			{"Сегодня", today},
+			{"7 дней", today.AddDate(0, 0, -6)},
+			{"30 дней", today.AddDate(0, 0, -29)},
+			{"Всё время", time.Time{}},
+		}
+
+		var text strings.Builder
+		text.WriteString("Статистика\n")
+
+		for _, el := range periods {
+			stats, err := c.FunnelRepo.GetStats(ctx, el.since)
+			if err != nil {
+				c.Log.Error("AdminStats: FunnelRepo.GetStats: %v", err)
+				HandleError(bot, update, "Временные неполадки на сервере")
+				return nil
+			}
+
+			text.WriteString("\n" + el.title + "\n")
+			text.WriteString(fmt.Sprintf("Нажали /start: %d\n", stats.Start))
+			text.WriteString(fmt.Sprintf("Открыли список каналов: %d (%s)\n", stats.SecondStep, percent(stats.SecondStep, stats.Start)))
+			text.WriteString(fmt.Sprintf("Не прошли проверку: %d (%s)\n", stats.ReadyFailed, percent(stats.ReadyFailed, stats.Start)))
+			text.WriteString(fmt.Sprintf("Получили ссылку: %d (%s)\n", stats.LinkIssued, percent(stats.LinkIssued, stats.Start)))
+		}
+
+		days, err := c.UserRepo.CountNewByDay(ctx, today.AddDate(0, 0, 1-statsNewUsersDays), today)
+		if err != nil {
+			c.Log.Error("AdminStats: UserRepo.CountNewByDay: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		text.WriteString("\nНовые пользователи\n")
+		for _, el := range days {
+			text.WriteString(fmt.Sprintf("%s  %d\n", el.Day.Format("02.01"), el.Count))
+		}
+		text.WriteString("\nПроценты — доля от нажавших /start")
+
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, text.String(), tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Обновить", "stats")),
+			))
+		if _, err := bot.Send(msg); err != nil && !isNotModified(err) {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func percent(part, total int) string {
+	if total == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%d%%", part*100/total)
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"errors"
+	"reflect"
+	"subscriber-check-bot/model"
+	"testing"
+)
+
+func TestReadyRecordsFunnel(t *testing.T) {
+	tests := []struct {
+		name       string
+		subscribed []int64
+		checkErr   error
+		want       []model.FunnelEvent
+	}{
+		{"link issued", []int64{-1001, -1002}, nil, []model.FunnelEvent{model.FunnelLinkIssued}},
+		{"channels missing", []int64{-1001}, nil, []model.FunnelEvent{model.FunnelReadyFailed}},
+		// the user is not to blame, the failure is not a step of the funnel
+		{"membership not checked", nil, errors.New("Bad Gateway"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newReadyTest(false)
+			test.subscribe(tt.subscribed...)
+			if tt.checkErr != nil {
+				test.bot.FailOn("GetChatMember", tt.checkErr)
+			}
+
+			test.press(t)
+
+			if !reflect.DeepEqual(test.funnel.events, tt.want) {
+				t.Fatalf("funnel events = %v, want %v", test.funnel.events, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"subscriber-check-bot/job"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/telegram"
+	"subscriber-check-bot/repo"
+)
+
+// Keys of the bot texts admins can edit.
+const (
+	TextStart         = "start"
+	TextSubscribe     = "subscribe"
+	TextReadyHint     = "ready_hint"
+	TextInvite        = "invite"
+	TextInviteRequest = "invite_request"
+	TextMissing       = "missing"
+	TextCaptcha       = "captcha"
+	TextCaptchaWrong  = "captcha_wrong"
+	TextCaptchaBlock  = "captcha_block"
+	TextDeclined      = "request_declined"
+	TextUnchecked     = "request_unchecked"
+)
+
+// defaultText.vars lists the placeholders the bot fills in, they are shown to admins editing the text.
+type defaultText struct {
+	key   string
+	title string
+	text  string
+	vars  string
+}
+
+// defaultTexts are used until an admin edits the text, in the order they are listed in the panel.
+var defaultTexts = []defaultText{
+	{TextStart, "Приветствие",
+		"Приветствуем Вас в нашем боте! Вам необходимо подписаться на все каналы, для получения доступа к основному каналу.", ""},
+	{TextSubscribe, "Список каналов", "Пожалуйста, подпишитесь на каналы", ""},
+	{TextReadyHint, "Подсказка ГОТОВО", "После подписки на все каналы нажмите на кнопку - ГОТОВО", ""},
+	{TextInvite, "Ссылка на канал", "Присоединяйся к секретному каналу:", ""},
+	{TextInviteRequest, "Ссылка-заявка на канал", "Отправь заявку на вступление в секретный канал, она будет одобрена автоматически:", ""},
+	{TextMissing, "Не все подписки", "Вы ещё не подписались на каналы:\n{channels}\n\nПосле подписки нажмите на кнопку - ГОТОВО",
+		"{channels} - каналы без подписки"},
+	{TextCaptcha, "Капча", "Подтвердите, что вы не бот. Сколько будет {question}?", "{question} - пример"},
+	{TextCaptchaWrong, "Неверный ответ на капчу", "Неверный ответ, осталось попыток: {attempts}", "{attempts} - оставшиеся попытки"},
+	{TextCaptchaBlock, "Блокировка капчи",
+		"Слишком много неверных ответов. Попробуйте снова после {time}, нажав на кнопку - ГОТОВО", "{time} - конец блокировки"},
+	{TextDeclined, "Заявка отклонена",
+		"Заявка в «{channel}» отклонена, вы не подписаны на каналы:\n{channels}\n\nПосле подписки нажмите на кнопку - ГОТОВО и отправьте заявку снова",
+		"{channel} - канал заявки, {channels} - каналы без подписки"},
+	{TextUnchecked, "Заявка не проверена",
+		"Не удалось проверить подписку на каналы, заявка в «{channel}» отклонена. Попробуйте позже, нажав на кнопку - ГОТОВО",
+		"{channel} - канал заявки"},
+	{job.TextWarning, "Предупреждение об отписке",
+		"Вы отписались от каналов:\n{channels}\n\nЕсли не подписаться снова в течение {period}, доступ к «{channel}» будет закрыт.",
+		"{channels} - каналы без подписки, {period} - срок, {channel} - главный канал"},
+}
+
+func findDefaultText(key string) (defaultText, bool) {
+	for _, el := range defaultTexts {
+		if el.key == key {
+			return el, true
+		}
+	}
+	return defaultText{}, false
+}
+
+// Texts renders bot texts from the message table, falling back to the defaults.
+type Texts struct {
+	Log     *logger.Logger
+	MsgRepo repo.MessageRepo
+}
+
+// Get returns the text stored under key or its default. A failed read is logged and the default is used,
+// so users keep getting answers while the database struggles.
+func (t *Texts) Get(ctx context.Context, key string) *model.Message {
+	message, err := t.MsgRepo.GetByKey(ctx, key)
+	if err == nil {
+		return message
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Log.Error("Texts.Get: MsgRepo.GetByKey: %v", err)
+	}
+
+	return t.Default(key)
+}
+
+// Render returns the text stored under key with its placeholders filled in, vars are placeholder and value pairs.
+func (t *Texts) Render(ctx context.Context, key string, vars ...string) *model.Message {
+	message := *t.Get(ctx, key)
+	if message.Message != nil {
+		text := strings.NewReplacer(vars...).Replace(*message.Message)
+		message.Message = &text
+	}
+
+	return &message
+}
+
+// Default returns the default text of key, not stored yet.
+func (t *Texts) Default(key string) *model.Message {
+	def, _ := findDefaultText(key)
+	return &model.Message{Key: &key, Message: &def.text}
+}
+
+// withText returns a copy of message with text appended to its text.
+func withText(message *model.Message, text string) *model.Message {
+	result := *message
+
+	body := text
+	if message.Message != nil && *message.Message != "" {
+		body = *message.Message + text
+	}
+	result.Message = &body
+
+	return &result
+}
+
+// editOrSend replaces the message with the pressed button by message. Messages with media can't
+// replace a text message and are sent anew.
+func editOrSend(bot telegram.Client, update *tgbotapi.Update, message *model.Message, rows ...[]tgbotapi.InlineKeyboardButton) error {
+	chatID := update.CallbackQuery.Message.Chat.ID
+
+	if message.FileID != nil || update.CallbackQuery.Message.Text == "" {
+		_, err := bot.Send(telegram.NewMessage(chatID, message, rows...))
+		return err
+	}
+
+	var text string
+	if message.Message != nil {
+		text = *message.Message
+	}
+
+	msg := tgbotapi.NewEditMessageText(chatID, update.CallbackQuery.Message.MessageID, text)
+	msg.ReplyMarkup = telegram.Keyboard(message, rows...)
+
+	_, err := bot.Send(msg)
+	return err
+}
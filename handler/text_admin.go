@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
+)
+
+func (c *CallbackHandler) AdminTexts() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, el := range defaultTexts {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(el.title, "text/"+el.key),
+			))
+		}
+
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, "Нажмите на текст, чтобы посмотреть или изменить его",
+			tgbotapi.NewInlineKeyboardMarkup(rows...))
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (c *CallbackHandler) AdminText() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		key := CallbackParams(ctx).String("key")
+		if _, ok := findDefaultText(key); !ok {
+			HandleError(bot, update, "Текст не найден")
+			return nil
+		}
+
+		text, markup := c.textView(ctx, key)
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, text, markup)
+		msg.DisableWebPagePreview = true
+		if _, err := bot.Send(msg); err != nil && !isNotModified(err) {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminTextPreview sends the text the way users see it.
+func (c *CallbackHandler) AdminTextPreview() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		key := CallbackParams(ctx).String("key")
+		if _, ok := findDefaultText(key); !ok {
+			HandleError(bot, update, "Текст не найден")
+			return nil
+		}
+
+		if _, err := bot.Send(telegram.NewMessage(update.CallbackQuery.Message.Chat.ID, c.Texts.Get(ctx, key))); err != nil {
+			c.Log.Error("AdminTextPreview: %v", err)
+			HandleError(bot, update, "Не удалось показать текст, проверьте его содержимое")
+			return nil
+		}
+
+		return nil
+	}
+}
+
+// AdminTextReset brings the default text back.
+func (c *CallbackHandler) AdminTextReset() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		key := CallbackParams(ctx).String("key")
+
+		message, err := c.MsgRepo.GetByKey(ctx, key)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			c.Log.Error("AdminTextReset: MsgRepo.GetByKey: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+		if err == nil {
+			if err := c.MsgRepo.DeleteByID(ctx, message.ID); err != nil {
+				c.Log.Error("AdminTextReset: MsgRepo.DeleteByID: %v", err)
+				HandleError(bot, update, "Временные неполадки на сервере")
+				return nil
+			}
+		}
+
+		return c.AdminText()(ctx, bot, update)
+	}
+}
+
+func (c *CallbackHandler) AdminTextEdit() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		return c.askText(ctx, bot, update, store.TextFieldText,
+			"Отправьте новый текст.\nДля отмены команды отправьте /cancel")
+	}
+}
+
+func (c *CallbackHandler) AdminTextMedia() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		return c.askText(ctx, bot, update, store.TextFieldMedia,
+			"Отправьте фото, видео, GIF или файл, который будет показан с текстом, или -, чтобы убрать его.\n"+
+				"Для отмены команды отправьте /cancel")
+	}
+}
+
+func (c *CallbackHandler) AdminTextButton() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		return c.askText(ctx, bot, update, store.TextFieldButton,
+			"Отправьте кнопку-ссылку в формате:\nТекст кнопки | https://example.com\nили -, чтобы убрать её.\n"+
+				"Для отмены команды отправьте /cancel")
+	}
+}
+
+func (c *CallbackHandler) askText(ctx context.Context, bot telegram.Client, update *tgbotapi.Update, field store.TextField, text string) error {
+	key := CallbackParams(ctx).String("key")
+	if _, ok := findDefaultText(key); !ok {
+		HandleError(bot, update, "Текст не найден")
+		return nil
+	}
+
+	if _, err := bot.Send(tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)); err != nil {
+		c.Log.Error("failed to send message: %v", err)
+		return err
+	}
+
+	if err := c.Store.Set(ctx, store.TextStore{Key: key, Field: field}, update.CallbackQuery.Message.Chat.ID); err != nil {
+		c.Log.Error("Store.Set: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// AdminTextInput is the state view receiving the new text, media or button.
+func (c *CallbackHandler) AdminTextInput() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		state, ok := State(ctx).(store.TextStore)
+		if !ok {
+			return nil
+		}
+
+		message, err := c.storedText(ctx, state.Key)
+		if err != nil {
+			c.Log.Error("AdminTextInput: storedText: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		input := strings.TrimSpace(update.Message.Text)
+
+		switch state.Field {
+		case store.TextFieldText:
+			if input == "" {
+				HandleError(bot, update, "Текст не может быть пустым, отправьте текст")
+				return nil
+			}
+			err = c.MsgRepo.UpdateTextByID(ctx, &input, message.ID)
+		case store.TextFieldMedia:
+			var fileID, fileType *string
+			if input != "-" {
+				media := messageFromUpdate(update.Message)
+				if media.FileID == nil {
+					HandleError(bot, update, "Отправьте фото, видео, GIF или файл, или -, чтобы убрать медиа")
+					return nil
+				}
+				fileID, fileType = media.FileID, (*string)(media.FileType)
+			}
+			err = c.MsgRepo.UpdateFileByID(ctx, fileID, fileType, message.ID)
+		case store.TextFieldButton:
+			var buttonText, buttonURL *string
+			if input != "-" {
+				text, link, ok := parseButton(input)
+				if !ok {
+					HandleError(bot, update, "Неверный формат кнопки, отправьте например:\nПодробнее | https://example.com")
+					return nil
+				}
+				buttonText, buttonURL = &text, &link
+			}
+			err = c.MsgRepo.UpdateButtonByID(ctx, buttonText, buttonURL, message.ID)
+		}
+		if err != nil {
+			c.Log.Error("AdminTextInput: MsgRepo: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		if err := c.Store.Delete(ctx, update.Message.Chat.ID); err != nil {
+			c.Log.Error("AdminTextInput: Store.Delete: %v", err)
+		}
+
+		text, markup := c.textView(ctx, state.Key)
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Сохранено\n\n"+text)
+		msg.ReplyMarkup = markup
+		msg.DisableWebPagePreview = true
+		if _, err := bot.Send(msg); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// storedText returns the stored text of key, storing the default first if it was never edited.
+func (c *CallbackHandler) storedText(ctx context.Context, key string) (*model.Message, error) {
+	message, err := c.MsgRepo.GetByKey(ctx, key)
+	if err == nil || !errors.Is(err, pgx.ErrNoRows) {
+		return message, err
+	}
+
+	message = c.Texts.Default(key)
+	if err := c.MsgRepo.Create(ctx, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (c *CallbackHandler) textView(ctx context.Context, key string) (string, tgbotapi.InlineKeyboardMarkup) {
+	def, _ := findDefaultText(key)
+	message := c.Texts.Get(ctx, key)
+
+	var text strings.Builder
+	text.WriteString("«" + def.title + "»\n\n")
+	if message.Message != nil {
+		text.WriteString(*message.Message)
+	}
+	if def.vars != "" {
+		text.WriteString("\n\nПодстановки: " + def.vars)
+	}
+
+	media := "нет"
+	if message.FileType != nil {
+		media = string(*message.FileType)
+	}
+	text.WriteString("\n\nМедиа: " + media)
+
+	button := "нет"
+	if message.ButtonText != nil && message.ButtonUrl != nil {
+		button = *message.ButtonText + " → " + *message.ButtonUrl
+	}
+	text.WriteString("\nКнопка: " + button)
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Изменить текст", "text_edit/"+key),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Медиа", "text_media/"+key),
+			tgbotapi.NewInlineKeyboardButtonData("Кнопка", "text_button/"+key),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Показать", "text_preview/"+key),
+			tgbotapi.NewInlineKeyboardButtonData("Сбросить", "text_reset/"+key),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Назад", "texts"),
+		),
+	)
+
+	return text.String(), markup
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTextsRender(t *testing.T) {
+	test := newReadyTest(false)
+
+	for _, el := range defaultTexts {
+		if el.vars == "" {
+			continue
+		}
+		t.Run(el.key, func(t *testing.T) {
+			var vars []string
+			for _, part := range strings.Split(el.vars, ", ") {
+				name, _, _ := strings.Cut(part, " ")
+				vars = append(vars, name, "value")
+			}
+
+			message := test.handler.Texts.Render(context.Background(), el.key, vars...)
+			if message.Message == nil || strings.ContainsAny(*message.Message, "{}") {
+				t.Fatalf("rendered %v, want every placeholder filled in", message.Message)
+			}
+			if !strings.Contains(*message.Message, "value") {
+				t.Fatalf("rendered %q without the values", *message.Message)
+			}
+		})
+	}
+}
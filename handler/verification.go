@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/membership"
+	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
+	"time"
+)
+
+// verificationFailuresDays is how many days the failed channels cover.
+const verificationFailuresDays = 30
+
+// recordVerification stores the outcome of Ready in the verification history. A failure to store it
+// is only logged, the user still gets the answer.
+func (c *CallbackHandler) recordVerification(ctx context.Context, userID int64, campaign *model.Campaign,
+	report membership.Report, linkIssued bool) {
+	verification := &model.Verification{
+		UserID:     userID,
+		CampaignID: campaign.ID,
+		CheckedAt:  time.Now(),
+		Passed:     c.Checker.Passed(report),
+		LinkIssued: linkIssued,
+		Channels:   make([]model.VerificationChannel, 0, len(report.Results)),
+	}
+
+	for _, el := range report.Results {
+		channelID := el.Channel.ID
+		verification.Channels = append(verification.Channels, model.VerificationChannel{
+			ChannelID:   &channelID,
+			ChannelName: el.Channel.Name,
+			Status:      string(el.Status),
+			Required:    el.Required,
+		})
+	}
+
+	if err := c.VerifyRepo.Create(ctx, verification); err != nil {
+		c.Log.Error("recordVerification: VerifyRepo.Create: %v", err)
+	}
+}
+
+// AdminVerifications shows the outcome of the latest verification of users and the required channels
+// failed most often.
+func (c *CallbackHandler) AdminVerifications() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		latest, err := c.VerifyRepo.CountLatestPerUser(ctx)
+		if err != nil {
+			c.Log.Error("AdminVerifications: VerifyRepo.CountLatestPerUser: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		failures, err := c.VerifyRepo.GetFailuresByChannel(ctx, time.Now().AddDate(0, 0, -verificationFailuresDays))
+		if err != nil {
+			c.Log.Error("AdminVerifications: VerifyRepo.GetFailuresByChannel: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		failed := latest.Users - latest.Passed
+
+		var text strings.Builder
+		text.WriteString("Проверки подписки\n\nПоследняя проверка пользователей\n")
+		text.WriteString(fmt.Sprintf("Проверялись: %d\n", latest.Users))
+		text.WriteString(fmt.Sprintf("Прошли: %d (%s)\n", latest.Passed, percent(latest.Passed, latest.Users)))
+		text.WriteString(fmt.Sprintf("Не прошли: %d (%s)\n", failed, percent(failed, latest.Users)))
+		text.WriteString(fmt.Sprintf("Получили ссылку: %d (%s)\n", latest.LinkIssued, percent(latest.LinkIssued, latest.Users)))
+
+		text.WriteString(fmt.Sprintf("\nНепройденные каналы за %d дней\n", verificationFailuresDays))
+		if len(failures) == 0 {
+			text.WriteString("Нет\n")
+		}
+		for _, el := range failures {
+			text.WriteString(fmt.Sprintf("%s: не подписаны %d, не удалось проверить %d\n", el.ChannelName, el.NotMember, el.Failed))
+		}
+
+		msg := tgbotapi.NewEditMessageTextAndMarkup(update.CallbackQuery.Message.Chat.ID,
+			update.CallbackQuery.Message.MessageID, text.String(), tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Проверка пользователя", "verification_user")),
+				tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Обновить", "verifications")),
+			))
+		if _, err := bot.Send(msg); err != nil && !isNotModified(err) {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminVerificationUser asks for the user whose latest verification is shown.
+func (c *CallbackHandler) AdminVerificationUser() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		text := "Отправьте никнейм пользователя, его ID или перешлите его сообщение.\nДля отмены команды отправьте /cancel"
+		if _, err := bot.Send(tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		if err := c.Store.Set(ctx, store.VerificationStore{}, update.CallbackQuery.Message.Chat.ID); err != nil {
+			c.Log.Error("Store.Set: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// AdminVerificationInput is the state view receiving the user and showing their latest verification
+// channel by channel.
+func (c *CallbackHandler) AdminVerificationInput() ViewFunc {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		if _, ok := State(ctx).(store.VerificationStore); !ok {
+			return nil
+		}
+
+		user, err := c.findUser(ctx, update.Message)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				HandleError(bot, update, "Пользователь не найден. Отправьте другой никнейм, ID или /cancel")
+				return nil
+			}
+			if errors.Is(err, errHiddenForward) {
+				HandleError(bot, update, "Пользователь скрыл свой аккаунт в пересланных сообщениях, отправьте его никнейм или ID")
+				return nil
+			}
+			if errors.Is(err, errAmbiguousUsername) {
+				HandleError(bot, update, "Этот никнейм есть у нескольких пользователей, отправьте ID или перешлите сообщение пользователя")
+				return nil
+			}
+			c.Log.Error("AdminVerificationInput: findUser: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		verification, err := c.VerifyRepo.GetLatest(ctx, user.ID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			c.Log.Error("AdminVerificationInput: VerifyRepo.GetLatest: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере")
+			return nil
+		}
+
+		if err := c.Store.Delete(ctx, update.Message.Chat.ID); err != nil {
+			c.Log.Error("AdminVerificationInput: Store.Delete: %v", err)
+		}
+
+		text := userName(user) + " ещё не проходил проверку"
+		if verification != nil && err == nil {
+			text = verificationText(user, verification)
+		}
+
+		if _, err := bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, text)); err != nil {
+			c.Log.Error("failed to send message: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+func verificationText(user *model.User, verification *model.Verification) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Последняя проверка %s: %s\n", userName(user), verification.CheckedAt.Format("02.01.2006 15:04")))
+
+	switch {
+	case verification.LinkIssued:
+		text.WriteString("Пройдена, ссылка выдана\n")
+	case verification.Passed:
+		text.WriteString("Пройдена, ссылка не выдана\n")
+	default:
+		text.WriteString("Не пройдена\n")
+	}
+
+	for _, el := range verification.Channels {
+		var status string
+		switch membership.Status(el.Status) {
+		case membership.StatusMember:
+			status = "подписан"
+		case membership.StatusNotMember:
+			status = "не подписан"
+		default:
+			status = "не удалось проверить"
+		}
+		if !el.Required {
+			status += " (необязательный)"
+		}
+		text.WriteString(fmt.Sprintf("\n%s: %s", el.ChannelName, status))
+	}
+
+	return text.String()
+}
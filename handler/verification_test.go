@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"errors"
+	"subscriber-check-bot/pkg/membership"
+	"testing"
+)
+
+func TestReadyRecordsVerification(t *testing.T) {
+	tests := []struct {
+		name           string
+		subscribed     []int64
+		checkErr       error
+		wantPassed     bool
+		wantLinkIssued bool
+		wantStatuses   []membership.Status
+	}{
+		{"subscribed to every channel", []int64{-1001, -1002}, nil, true, true,
+			[]membership.Status{membership.StatusMember, membership.StatusMember}},
+		{"subscribed to one channel", []int64{-1001}, nil, false, false,
+			[]membership.Status{membership.StatusMember, membership.StatusNotMember}},
+		{"membership not checked", nil, errors.New("Bad Gateway"), false, false,
+			[]membership.Status{membership.StatusFailed, membership.StatusFailed}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newReadyTest(false)
+			test.subscribe(tt.subscribed...)
+			if tt.checkErr != nil {
+				test.bot.FailOn("GetChatMember", tt.checkErr)
+			}
+
+			test.press(t)
+
+			if len(test.verifies.verifications) != 1 {
+				t.Fatalf("recorded %d verifications, want 1", len(test.verifies.verifications))
+			}
+			verification := test.verifies.verifications[0]
+			if verification.UserID != testUserID || verification.CampaignID != test.campaign.ID {
+				t.Errorf("recorded user %d, campaign %d, want %d, %d",
+					verification.UserID, verification.CampaignID, testUserID, test.campaign.ID)
+			}
+			if verification.Passed != tt.wantPassed || verification.LinkIssued != tt.wantLinkIssued {
+				t.Errorf("passed = %t, link issued = %t, want %t, %t",
+					verification.Passed, verification.LinkIssued, tt.wantPassed, tt.wantLinkIssued)
+			}
+
+			if len(verification.Channels) != len(tt.wantStatuses) {
+				t.Fatalf("recorded %d channels, want %d", len(verification.Channels), len(tt.wantStatuses))
+			}
+			for i, el := range verification.Channels {
+				want := test.campaign.Channels[i]
+				if el.ChannelID == nil || *el.ChannelID != want.ID || el.ChannelName != want.Name || !el.Required {
+					t.Errorf("channel %d = %+v, want required %q", i, el, want.Name)
+				}
+				if el.Status != string(tt.wantStatuses[i]) {
+					t.Errorf("channel %q status = %s, want %s", el.ChannelName, el.Status, tt.wantStatuses[i])
+				}
+			}
+		})
+	}
+}
@@ -2,29 +2,54 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"subscriber-check-bot/model"
 	"subscriber-check-bot/pkg/logger"
 	"subscriber-check-bot/pkg/store"
+	"subscriber-check-bot/pkg/telegram"
 	"subscriber-check-bot/repo"
 )
 
 type ViewHandler struct {
 	Log   *logger.Logger
-	Store *store.Store
+	Store store.Store
+	Texts *Texts
 
-	ChRepo  repo.ChannelRepo
-	MsgRepo repo.MessageRepo
+	ChRepo       repo.ChannelRepo
+	MsgRepo      repo.MessageRepo
+	UserRepo     repo.UserRepo
+	CampaignRepo repo.CampaignRepo
+	FunnelRepo   repo.FunnelRepo
+
+	// BotName is the username of the bot, referral links point to it.
+	BotName string
 }
 
+// GetStart greets the user. A campaign deep-link (/start c_<slug>) makes the campaign the user's one,
+// otherwise the user keeps the campaign they came with before.
 func (v *ViewHandler) GetStart() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
-		text := "Приветствуем Вас в нашем боте! Вам необходимо подписаться на все каналы, для получения доступа к основному каналу."
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		campaign, err := v.startCampaign(ctx, update)
+		if err != nil {
+			v.Log.Error("GetStart: startCampaign: %v", err)
+			HandleError(bot, update, "Временные неполадки на сервере, пытаемся исправить")
+			return nil
+		}
 
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
-		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("Принять участие", "second_step"),
-			))
+		recordFunnel(ctx, v.Log, v.FunnelRepo, update.Message.From.ID, model.FunnelStart, campaign.ID)
+
+		text := v.Texts.Get(ctx, TextStart)
+		if campaign.ID != model.DefaultCampaignID && campaign.Target != nil {
+			text = withText(text, "\n\nДоступ к каналу «"+campaign.Target.Name+"»")
+		}
+
+		msg := telegram.NewMessage(update.Message.Chat.ID, text, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Принять участие", fmt.Sprintf("second_step/%d", campaign.ID)),
+		))
 
 		if _, err := bot.Send(msg); err != nil {
 			v.Log.Error("failed to send message: %v", err)
@@ -35,14 +60,68 @@ func (v *ViewHandler) GetStart() ViewFunc {
 	}
 }
 
+func (v *ViewHandler) startCampaign(ctx context.Context, update *tgbotapi.Update) (*model.Campaign, error) {
+	userID := update.Message.From.ID
+
+	if slug, ok := strings.CutPrefix(update.Message.CommandArguments(), campaignPayloadPrefix); ok {
+		campaign, err := v.CampaignRepo.GetBySlug(ctx, slug)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		if err == nil {
+			if err := v.UserRepo.UpdateCampaign(ctx, userID, campaign.ID); err != nil {
+				return nil, err
+			}
+			return campaign, nil
+		}
+	}
+
+	user, err := v.UserRepo.GetUserByID(ctx, userID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil && user.CampaignID != nil {
+		campaign, err := v.CampaignRepo.GetByID(ctx, *user.CampaignID)
+		if err == nil {
+			return campaign, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	return v.CampaignRepo.GetByID(ctx, model.DefaultCampaignID)
+}
+
 func (v *ViewHandler) AdminGetPanel() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
 		text := "Список команд доступных администратору"
 
 		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
 		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("Назначить главный канал", "set_main_channel"),
+				tgbotapi.NewInlineKeyboardButtonData("Каналы", "channels"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Кампании", "campaigns"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Правила каналов", "channel_rules"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Статистика", "stats"),
+				tgbotapi.NewInlineKeyboardButtonData("Топ рефереров", "top_referrers"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Проверки подписки", "verifications"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Капча", "captcha"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Рассылка", "broadcast"),
+				tgbotapi.NewInlineKeyboardButtonData("Тексты", "texts"),
 			),
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("Управление администраторами", "admin_role_setting"),
@@ -59,8 +138,11 @@ func (v *ViewHandler) AdminGetPanel() ViewFunc {
 }
 
 func (v *ViewHandler) AdminCancelCommand() ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update *tgbotapi.Update) error {
-		v.Store.Delete(update.Message.Chat.ID)
+	return func(ctx context.Context, bot telegram.Client, update *tgbotapi.Update) error {
+		if err := v.Store.Delete(ctx, update.Message.Chat.ID); err != nil {
+			v.Log.Error("AdminCancelCommand: Store.Delete: %v", err)
+			return err
+		}
 
 		text := "Все команды отменены"
 		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// listenWebhook registers the webhook in Telegram and starts the http server receiving updates.
+// The returned func shuts the server down and deletes the webhook, the returned channel gets the error
+// the server stopped with.
+func (b *Bot) listenWebhook() (tgbotapi.UpdatesChannel, func(), <-chan error, error) {
+	webhookURL, err := url.Parse(b.cfg.Telegram.Webhook.URL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	path := webhookURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	updates := make(chan tgbotapi.Update)
+
+	mux := http.NewServeMux()
+	mux.Handle(path, b.WebhookHandler(updates))
+
+	server := &http.Server{
+		Addr:              b.cfg.Telegram.Webhook.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// listening before registering the webhook, so a busy address fails the start instead of losing updates
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	params := tgbotapi.Params{"url": webhookURL.String()}
+	params.AddNonEmpty("secret_token", b.cfg.Telegram.Webhook.SecretToken)
+	if err := params.AddInterface("allowed_updates", allowedUpdates); err != nil {
+		_ = server.Close()
+		return nil, nil, nil, err
+	}
+
+	if _, err := b.bot.MakeRequest("setWebhook", params); err != nil {
+		_ = server.Close()
+		return nil, nil, nil, err
+	}
+	b.log.Info("webhook registered on %s, listening %s", webhookURL.Redacted(), server.Addr)
+
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			b.log.Error("webhook: server.Shutdown: %v", err)
+		}
+
+		if _, err := b.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			b.log.Error("webhook: deleteWebhook: %v", err)
+		}
+	}
+
+	return updates, stop, serveErr, nil
+}
+
+// WebhookHandler decodes updates posted by Telegram and passes them to updates.
+// Requests without the configured secret token are rejected, all of them if no secret is configured. The response is written only
+// after the update is accepted, so Telegram redelivers updates we had no room for.
+func (b *Bot) WebhookHandler(updates chan<- tgbotapi.Update) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		secretToken := b.cfg.Telegram.Webhook.SecretToken
+		if secretToken == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(secretTokenHeader)), []byte(secretToken)) != 1 {
+			b.log.Error("webhook: invalid secret token from %s", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			b.log.Error("webhook: json.Decode: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case updates <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case <-b.stopping:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"subscriber-check-bot/config"
+	"subscriber-check-bot/pkg/logger"
+	"testing"
+	"time"
+)
+
+// recordedUpdate is a callback_query update as Telegram posts it.
+const recordedUpdate = `{"update_id":815234567,"callback_query":{"id":"4382bfdwdsb323b2d9","from":{"id":100,
+"is_bot":false,"first_name":"Ivan","username":"ivan","language_code":"ru"},"message":{"message_id":10,"date":1760000000,
+"chat":{"id":100,"first_name":"Ivan","username":"ivan","type":"private"},"text":"Подпишитесь на каналы"},
+"chat_instance":"-8923841223452","data":"ready"}}`
+
+func TestWebhookHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		header   string
+		body     string
+		wantCode int
+		wantID   int
+	}{
+		{"recorded update", "s3cret", "s3cret", recordedUpdate, http.StatusOK, 815234567},
+		{"wrong token", "s3cret", "guess", recordedUpdate, http.StatusUnauthorized, 0},
+		{"missing token", "s3cret", "", recordedUpdate, http.StatusUnauthorized, 0},
+		{"no secret configured", "", "", recordedUpdate, http.StatusUnauthorized, 0},
+		{"malformed json", "s3cret", "s3cret", `{"update_id":`, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Telegram.Webhook.SecretToken = tt.secret
+			b := &Bot{log: logger.New(), cfg: cfg, stopping: make(chan struct{})}
+
+			updates := make(chan tgbotapi.Update, 1)
+			server := httptest.NewServer(b.WebhookHandler(updates))
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			if tt.header != "" {
+				req.Header.Set(secretTokenHeader, tt.header)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("POST: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+
+			select {
+			case update := <-updates:
+				if tt.wantID == 0 {
+					t.Fatalf("update %d accepted, want rejected", update.UpdateID)
+				}
+				if update.UpdateID != tt.wantID || update.CallbackQuery == nil || update.CallbackQuery.Data != "ready" {
+					t.Fatalf("update = %+v, want the recorded callback", update)
+				}
+			case <-time.After(50 * time.Millisecond):
+				if tt.wantID != 0 {
+					t.Fatal("update was not passed on")
+				}
+			}
+		})
+	}
+}
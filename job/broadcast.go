@@ -0,0 +1,215 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"net/http"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/telegram"
+	"subscriber-check-bot/repo"
+	"time"
+)
+
+// progressEvery is how many users are sent to between progress updates and cancellation checks.
+const progressEvery = 25
+
+// Broadcaster sends queued broadcasts to every user, one message per SendInterval on top of the
+// client rate limits, so the bot keeps answering users meanwhile. The admin message of the broadcast
+// shows the progress and the final summary. A broadcast interrupted by a restart continues where it stopped.
+type Broadcaster struct {
+	Log           *logger.Logger
+	Bot           telegram.Client
+	UserRepo      repo.UserRepo
+	MsgRepo       repo.MessageRepo
+	BroadcastRepo repo.BroadcastRepo
+
+	Interval     time.Duration
+	SendInterval time.Duration
+}
+
+func (b *Broadcaster) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.sendUnfinished(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *Broadcaster) sendUnfinished(ctx context.Context) {
+	broadcasts, err := b.BroadcastRepo.GetUnfinished(ctx)
+	if err != nil {
+		b.Log.Error("Broadcaster: BroadcastRepo.GetUnfinished: %v", err)
+		return
+	}
+
+	for _, el := range broadcasts {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := b.send(ctx, &el); err != nil {
+			b.Log.Error("Broadcaster: broadcast %d: %v", el.ID, err)
+		}
+	}
+}
+
+func (b *Broadcaster) send(ctx context.Context, broadcast *model.Broadcast) error {
+	message, err := b.MsgRepo.GetByID(ctx, broadcast.MessageID)
+	if err != nil {
+		return fmt.Errorf("MsgRepo.GetByID: %w", err)
+	}
+
+	// a resumed broadcast continues after the last user it was sent to, users who joined meanwhile get it too
+	users, err := b.UserRepo.GetUsersAfter(ctx, broadcast.LastUserID)
+	if err != nil {
+		return fmt.Errorf("UserRepo.GetUsersAfter: %w", err)
+	}
+
+	if broadcast.StartedAt == nil {
+		broadcast.Total = len(users)
+	}
+	if err := b.BroadcastRepo.Start(ctx, broadcast.ID, broadcast.Total, time.Now()); err != nil {
+		return fmt.Errorf("BroadcastRepo.Start: %w", err)
+	}
+	b.Log.Info("Broadcaster: broadcast %d to %d users started", broadcast.ID, broadcast.Total)
+
+	status := model.BroadcastFinished
+
+	for i, user := range users {
+		if i > 0 && i%progressEvery == 0 {
+			cancelled, err := b.saveProgress(ctx, broadcast)
+			if err != nil {
+				return err
+			}
+			if cancelled {
+				status = model.BroadcastCancelled
+				break
+			}
+		}
+
+		if !b.sendTo(ctx, user.ID, message, broadcast) {
+			b.stop(broadcast)
+			return nil
+		}
+		broadcast.LastUserID = user.ID
+
+		select {
+		case <-time.After(b.SendInterval):
+		case <-ctx.Done():
+			b.stop(broadcast)
+			return nil
+		}
+	}
+
+	if err := b.BroadcastRepo.UpdateProgress(ctx, broadcast); err != nil {
+		return fmt.Errorf("BroadcastRepo.UpdateProgress: %w", err)
+	}
+	if status == model.BroadcastFinished {
+		// the admin may have cancelled it while the last users were sent to
+		current, err := b.BroadcastRepo.GetByID(ctx, broadcast.ID)
+		if err != nil {
+			return fmt.Errorf("BroadcastRepo.GetByID: %w", err)
+		}
+		if current.Status == model.BroadcastCancelled {
+			status = model.BroadcastCancelled
+		}
+	}
+	if err := b.BroadcastRepo.Finish(ctx, broadcast.ID, status, time.Now()); err != nil {
+		return fmt.Errorf("BroadcastRepo.Finish: %w", err)
+	}
+	broadcast.Status = status
+
+	b.editProgress(broadcast)
+	b.Log.Info("Broadcaster: broadcast %d %s: delivered %d, blocked %d, failed %d", broadcast.ID, status,
+		broadcast.Delivered, broadcast.Blocked, broadcast.Failed)
+
+	return nil
+}
+
+// sendTo sends the message to the user and counts the outcome. It reports false if the send was
+// interrupted by the shutdown, the user is then neither counted nor skipped after the restart.
+func (b *Broadcaster) sendTo(ctx context.Context, userID int64, message *model.Message, broadcast *model.Broadcast) bool {
+	_, err := b.Bot.Send(telegram.NewMessage(userID, message))
+
+	var apiErr *tgbotapi.Error
+	switch {
+	case err == nil:
+		broadcast.Delivered++
+	case ctx.Err() != nil:
+		return false
+	case errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden:
+		broadcast.Blocked++
+	default:
+		broadcast.Failed++
+		b.Log.Error("Broadcaster: send to %d: %v", userID, err)
+	}
+
+	return true
+}
+
+// stop stores the progress of the broadcast interrupted by the shutdown, it continues after the restart.
+func (b *Broadcaster) stop(broadcast *model.Broadcast) {
+	if err := b.BroadcastRepo.UpdateProgress(context.Background(), broadcast); err != nil {
+		b.Log.Error("Broadcaster: BroadcastRepo.UpdateProgress: %v", err)
+	}
+}
+
+// saveProgress stores the counters, updates the admin message and reports whether the broadcast was cancelled.
+func (b *Broadcaster) saveProgress(ctx context.Context, broadcast *model.Broadcast) (bool, error) {
+	if err := b.BroadcastRepo.UpdateProgress(ctx, broadcast); err != nil {
+		return false, fmt.Errorf("BroadcastRepo.UpdateProgress: %w", err)
+	}
+
+	current, err := b.BroadcastRepo.GetByID(ctx, broadcast.ID)
+	if err != nil {
+		return false, fmt.Errorf("BroadcastRepo.GetByID: %w", err)
+	}
+	if current.Status == model.BroadcastCancelled {
+		return true, nil
+	}
+
+	broadcast.Status = model.BroadcastRunning
+	b.editProgress(broadcast)
+	return false, nil
+}
+
+func (b *Broadcaster) editProgress(broadcast *model.Broadcast) {
+	msg := tgbotapi.NewEditMessageText(broadcast.ChatID, broadcast.ProgressMessageID, BroadcastText(broadcast))
+	if broadcast.Status == model.BroadcastRunning {
+		markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Остановить", fmt.Sprintf("broadcast_cancel/%d", broadcast.ID)),
+		))
+		msg.ReplyMarkup = &markup
+	}
+
+	if _, err := b.Bot.Send(msg); err != nil {
+		b.Log.Error("Broadcaster: edit progress of broadcast %d: %v", broadcast.ID, err)
+	}
+}
+
+// BroadcastText describes the state of the broadcast for the admin.
+func BroadcastText(broadcast *model.Broadcast) string {
+	var title string
+	switch broadcast.Status {
+	case model.BroadcastPending:
+		return "Рассылка поставлена в очередь"
+	case model.BroadcastRunning:
+		title = fmt.Sprintf("Рассылка идёт: %d из %d", broadcast.Processed(), broadcast.Total)
+	case model.BroadcastCancelled:
+		title = fmt.Sprintf("Рассылка остановлена: %d из %d", broadcast.Processed(), broadcast.Total)
+	default:
+		title = "Рассылка завершена"
+	}
+
+	return fmt.Sprintf("%s\nДоставлено: %d\nЗаблокировали бота: %d\nОшибок: %d",
+		title, broadcast.Delivered, broadcast.Blocked, broadcast.Failed)
+}
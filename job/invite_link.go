@@ -0,0 +1,70 @@
+package job
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/telegram"
+	"subscriber-check-bot/repo"
+	"time"
+)
+
+// InviteLinkRevoker revokes invite links that expired without being used.
+type InviteLinkRevoker struct {
+	Log      *logger.Logger
+	Bot      telegram.Client
+	LinkRepo repo.InviteLinkRepo
+
+	Interval time.Duration
+}
+
+func (r *InviteLinkRevoker) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.revokeExpired(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *InviteLinkRevoker) revokeExpired(ctx context.Context) {
+	links, err := r.LinkRepo.GetExpiredUnused(ctx, time.Now())
+	if err != nil {
+		r.Log.Error("InviteLinkRevoker: LinkRepo.GetExpiredUnused: %v", err)
+		return
+	}
+
+	for _, el := range links {
+		if ctx.Err() != nil {
+			return
+		}
+
+		revoke := tgbotapi.RevokeChatInviteLinkConfig{
+			ChatConfig: tgbotapi.ChatConfig{ChatID: el.ChannelTelegramID},
+			InviteLink: el.InviteLink,
+		}
+
+		if _, err := r.Bot.Request(revoke); err != nil {
+			// 400 means the link or the chat is gone, there is nothing left to revoke
+			var apiErr *tgbotapi.Error
+			if !errors.As(err, &apiErr) || apiErr.Code != 400 {
+				r.Log.Error("InviteLinkRevoker: revoke link %d: %v", el.ID, err)
+				continue
+			}
+		}
+
+		if err := r.LinkRepo.MarkRevoked(ctx, el.ID, time.Now()); err != nil {
+			r.Log.Error("InviteLinkRevoker: LinkRepo.MarkRevoked: %v", err)
+		}
+	}
+
+	if len(links) > 0 {
+		r.Log.Info("InviteLinkRevoker: %d expired links processed", len(links))
+	}
+}
@@ -0,0 +1,217 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/membership"
+	"subscriber-check-bot/pkg/telegram"
+	"subscriber-check-bot/repo"
+	"time"
+)
+
+// TextWarning is the key of the warning text, admins edit it with the other bot texts.
+const TextWarning = "reverify_warning"
+
+// TextRenderer renders the bot text stored under key, vars are placeholder and value pairs.
+type TextRenderer interface {
+	Render(ctx context.Context, key string, vars ...string) *model.Message
+}
+
+// Reverifier periodically checks that users who joined a campaign target channel through an issued link
+// are still subscribed to the required channels of the campaign. A user who is not gets a warning in DM
+// and is removed from the target channel if still unsubscribed after GracePeriod. With DryRun it only
+// logs what it would do.
+type Reverifier struct {
+	Log     *logger.Logger
+	Bot     telegram.Client
+	Checker *membership.Checker
+	Cache   *membership.Cache
+	Texts   TextRenderer
+
+	CampaignRepo repo.CampaignRepo
+	LinkRepo     repo.InviteLinkRepo
+	WarningRepo  repo.AccessWarningRepo
+
+	Interval    time.Duration
+	GracePeriod time.Duration
+	DryRun      bool
+}
+
+func (r *Reverifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.reverify(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Reverifier) reverify(ctx context.Context) {
+	links, err := r.LinkRepo.GetUsed(ctx)
+	if err != nil {
+		r.Log.Error("Reverifier: LinkRepo.GetUsed: %v", err)
+		return
+	}
+
+	campaigns := make(map[int]*model.Campaign)
+
+	for _, el := range links {
+		if ctx.Err() != nil {
+			return
+		}
+
+		campaign, ok := campaigns[el.CampaignID]
+		if !ok {
+			campaign, err = r.CampaignRepo.GetByID(ctx, el.CampaignID)
+			if err != nil {
+				r.Log.Error("Reverifier: CampaignRepo.GetByID: %v", err)
+				continue
+			}
+			campaigns[el.CampaignID] = campaign
+		}
+
+		// the channel is no longer the target of the campaign the link was issued for
+		if campaign.Target == nil || campaign.Target.ID != el.ChannelID {
+			continue
+		}
+
+		if err := r.reverifyUser(ctx, *campaign.Target, campaign.Channels, el); err != nil {
+			r.Log.Error("Reverifier: user %d: %v", el.UserID, err)
+		}
+	}
+}
+
+func (r *Reverifier) reverifyUser(ctx context.Context, main model.Channel, channels []model.Channel, link model.InviteLink) error {
+	warning, err := r.WarningRepo.Get(ctx, link.UserID, main.ID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("WarningRepo.Get: %w", err)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		warning = nil
+	}
+
+	// already removed and didn't come back with a newer link
+	if warning != nil && warning.RemovedAt != nil && link.UsedAt.Before(*warning.RemovedAt) {
+		return nil
+	}
+
+	r.Cache.InvalidateUser(link.UserID)
+
+	inMain := r.Checker.Check(ctx, []model.Channel{main}, link.UserID)
+	if inMain.Results[0].Status == membership.StatusNotMember {
+		if warning != nil && warning.RemovedAt == nil {
+			return r.WarningRepo.Delete(ctx, link.UserID, main.ID)
+		}
+		return nil
+	}
+
+	report := r.Checker.Check(ctx, channels, link.UserID)
+	missing := report.Missing()
+
+	if len(missing) == 0 {
+		if warning != nil {
+			if err := r.WarningRepo.Delete(ctx, link.UserID, main.ID); err != nil {
+				return fmt.Errorf("WarningRepo.Delete: %w", err)
+			}
+			r.Log.Info("Reverifier: user %d subscribed again, warning dropped", link.UserID)
+		}
+		return nil
+	}
+
+	if warning == nil || warning.RemovedAt != nil {
+		return r.warn(ctx, main, missing, link.UserID)
+	}
+
+	if time.Since(warning.WarnedAt) < r.GracePeriod {
+		return nil
+	}
+
+	return r.remove(ctx, main, link.UserID)
+}
+
+func (r *Reverifier) warn(ctx context.Context, main model.Channel, missing []model.Channel, userID int64) error {
+	if r.DryRun {
+		r.Log.Info("Reverifier: dry run: would warn user %d about %d missing channels", userID, len(missing))
+		return nil
+	}
+
+	names := make([]string, 0, len(missing))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, el := range missing {
+		names = append(names, "• "+el.Name)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL(el.Name, el.URL)))
+	}
+
+	text := r.Texts.Render(ctx, TextWarning,
+		"{channels}", strings.Join(names, "\n"),
+		"{period}", formatDuration(r.GracePeriod),
+		"{channel}", main.Name,
+	)
+
+	if _, err := r.Bot.Send(telegram.NewMessage(userID, text, rows...)); err != nil {
+		// the user may have blocked the bot, the grace period still applies
+		r.Log.Error("Reverifier: failed to warn user %d: %v", userID, err)
+	}
+
+	if err := r.WarningRepo.Create(ctx, &model.AccessWarning{
+		UserID:    userID,
+		ChannelID: main.ID,
+		WarnedAt:  time.Now(),
+	}); err != nil {
+		return fmt.Errorf("WarningRepo.Create: %w", err)
+	}
+
+	r.Log.Info("Reverifier: user %d warned", userID)
+	return nil
+}
+
+func (r *Reverifier) remove(ctx context.Context, main model.Channel, userID int64) error {
+	if r.DryRun {
+		r.Log.Info("Reverifier: dry run: would remove user %d from %s", userID, main.Name)
+		return nil
+	}
+
+	member := tgbotapi.ChatMemberConfig{ChatID: main.ChannelTelegramId, UserID: userID}
+
+	if _, err := r.Bot.Request(tgbotapi.BanChatMemberConfig{ChatMemberConfig: member}); err != nil {
+		return fmt.Errorf("ban: %w", err)
+	}
+	// unban right away, so the user can come back after subscribing again
+	if _, err := r.Bot.Request(tgbotapi.UnbanChatMemberConfig{ChatMemberConfig: member, OnlyIfBanned: true}); err != nil {
+		r.Log.Error("Reverifier: unban user %d: %v", userID, err)
+	}
+
+	if err := r.WarningRepo.MarkRemoved(ctx, userID, main.ID, time.Now()); err != nil {
+		return fmt.Errorf("WarningRepo.MarkRemoved: %w", err)
+	}
+
+	text := fmt.Sprintf("Доступ к «%s» закрыт, так как вы отписались от обязательных каналов. "+
+		"Чтобы вернуться, подпишитесь снова и отправьте /start", main.Name)
+	if _, err := r.Bot.Send(tgbotapi.NewMessage(userID, text)); err != nil {
+		r.Log.Error("Reverifier: failed to notify user %d: %v", userID, err)
+	}
+
+	r.Log.Info("Reverifier: user %d removed from %s", userID, main.Name)
+	return nil
+}
+
+func formatDuration(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d дн.", d/(24*time.Hour))
+	}
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d ч.", d/time.Hour)
+	}
+	return fmt.Sprintf("%d мин.", d/time.Minute)
+}
@@ -0,0 +1,2 @@
+drop table if exists processed_update;
+drop table if exists update_offset;
@@ -0,0 +1,5 @@
+alter table invite_link drop column if exists campaign_id;
+alter table "user" drop column if exists campaign_id;
+
+drop table if exists campaign_channel;
+drop table if exists campaign;
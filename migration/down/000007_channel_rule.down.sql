@@ -0,0 +1,5 @@
+alter table channel drop column if exists sort_order;
+alter table channel drop column if exists ends_at;
+alter table channel drop column if exists starts_at;
+alter table channel drop column if exists allow_restricted;
+alter table channel drop column if exists is_required;
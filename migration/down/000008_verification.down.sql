@@ -0,0 +1,2 @@
+drop table if exists verification_channel;
+drop table if exists verification;
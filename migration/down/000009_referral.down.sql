@@ -0,0 +1,4 @@
+drop index if exists user_referrer_id_idx;
+
+alter table "user" drop column if exists referral_confirmed_at;
+alter table "user" drop column if exists referrer_id;
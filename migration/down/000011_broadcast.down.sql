@@ -0,0 +1,3 @@
+drop table if exists broadcast;
+
+alter table message drop column if exists file_type;
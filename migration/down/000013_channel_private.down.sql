@@ -0,0 +1 @@
+update channel set channel_status = 'secondary' where channel_status = 'private';
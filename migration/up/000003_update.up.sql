@@ -0,0 +1,15 @@
+create table if not exists update_offset(
+    id              int default 1 not null,
+    update_offset   bigint not null,
+    updated_at      timestamp default now() not null,
+    primary key (id),
+    check (id = 1)
+);
+
+create table if not exists processed_update(
+    update_id     bigint not null,
+    processed_at  timestamp default now() not null,
+    primary key (update_id)
+);
+
+create index if not exists processed_update_processed_at_idx on processed_update (processed_at);
@@ -0,0 +1,15 @@
+create table if not exists invite_link(
+    id                    int generated always as identity,
+    user_id               bigint not null,
+    channel_id            int not null references channel(id) on delete cascade,
+    invite_link           varchar(250) not null unique,
+    creates_join_request  boolean default false not null,
+    created_at            timestamp default now() not null,
+    expires_at            timestamp not null,
+    used_at               timestamp null,
+    revoked_at            timestamp null,
+    primary key (id)
+);
+
+create index if not exists invite_link_user_channel_idx on invite_link (user_id, channel_id);
+create index if not exists invite_link_expires_at_idx on invite_link (expires_at) where used_at is null and revoked_at is null;
@@ -0,0 +1,7 @@
+create table if not exists access_warning(
+    user_id     bigint not null,
+    channel_id  int not null references channel(id) on delete cascade,
+    warned_at   timestamp not null,
+    removed_at  timestamp null,
+    primary key (user_id, channel_id)
+);
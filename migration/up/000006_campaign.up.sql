@@ -0,0 +1,18 @@
+create table if not exists campaign(
+    id                 int generated always as identity,
+    name               varchar(200) not null,
+    slug               varchar(50) not null unique,
+    target_channel_id  int not null references channel(id) on delete cascade,
+    created_at         timestamp default now() not null,
+    primary key (id)
+);
+
+create table if not exists campaign_channel(
+    campaign_id  int not null references campaign(id) on delete cascade,
+    channel_id   int not null references channel(id) on delete cascade,
+    primary key (campaign_id, channel_id)
+);
+
+alter table "user" add column if not exists campaign_id int null references campaign(id) on delete set null;
+
+alter table invite_link add column if not exists campaign_id int null references campaign(id) on delete set null;
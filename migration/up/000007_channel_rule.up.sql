@@ -0,0 +1,5 @@
+alter table channel add column if not exists is_required boolean default true not null;
+alter table channel add column if not exists allow_restricted boolean default false not null;
+alter table channel add column if not exists starts_at timestamptz null;
+alter table channel add column if not exists ends_at timestamptz null;
+alter table channel add column if not exists sort_order int default 0 not null;
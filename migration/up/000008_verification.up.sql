@@ -0,0 +1,21 @@
+create table if not exists verification(
+    id           bigint generated always as identity,
+    user_id      bigint not null,
+    campaign_id  int null references campaign(id) on delete set null,
+    checked_at   timestamp default now() not null,
+    passed       boolean not null,
+    link_issued  boolean not null,
+    primary key (id)
+);
+
+create index if not exists verification_user_id_idx on verification (user_id, checked_at);
+
+create table if not exists verification_channel(
+    verification_id  bigint not null references verification(id) on delete cascade,
+    channel_id       int null references channel(id) on delete set null,
+    channel_name     varchar(200) not null,
+    status           varchar(20) not null,
+    required         boolean not null
+);
+
+create index if not exists verification_channel_verification_id_idx on verification_channel (verification_id);
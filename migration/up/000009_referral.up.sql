@@ -0,0 +1,4 @@
+alter table "user" add column if not exists referrer_id bigint null references "user"(id) on delete set null;
+alter table "user" add column if not exists referral_confirmed_at timestamp null;
+
+create index if not exists user_referrer_id_idx on "user" (referrer_id) where referral_confirmed_at is not null;
@@ -0,0 +1,6 @@
+create table if not exists setting(
+    key         varchar(100) not null,
+    value       text not null,
+    updated_at  timestamp default now() not null,
+    primary key (key)
+);
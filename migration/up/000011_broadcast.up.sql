@@ -0,0 +1,18 @@
+alter table message add column if not exists file_type varchar(20) null;
+
+create table if not exists broadcast(
+    id                   int generated always as identity,
+    message_id           int not null unique references message(id) on delete cascade,
+    status               varchar(20) default 'pending' not null,
+    chat_id              bigint not null,
+    progress_message_id  int not null,
+    total                int default 0 not null,
+    delivered            int default 0 not null,
+    blocked              int default 0 not null,
+    failed               int default 0 not null,
+    last_user_id         bigint default 0 not null,
+    created_at           timestamp default now() not null,
+    started_at           timestamp null,
+    finished_at          timestamp null,
+    primary key (id)
+);
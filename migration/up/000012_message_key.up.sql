@@ -0,0 +1 @@
+alter table message add column if not exists key varchar(50) null unique;
@@ -0,0 +1 @@
+alter type role add value if not exists 'private';
@@ -0,0 +1,10 @@
+create table if not exists funnel_event(
+    id           bigint generated always as identity,
+    user_id      bigint not null,
+    event        varchar(30) not null,
+    campaign_id  int null references campaign(id) on delete set null,
+    created_at   timestamp not null,
+    primary key (id)
+);
+
+create index if not exists funnel_event_created_at_idx on funnel_event (created_at, event);
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// AccessWarning is a warning sent to a user who unsubscribed from a required channel
+// after getting access to the main channel.
+type AccessWarning struct {
+	UserID    int64      `json:"user_id"`
+	ChannelID int        `json:"channel_id"`
+	WarnedAt  time.Time  `json:"warned_at"`
+	RemovedAt *time.Time `json:"removed_at"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+type BroadcastStatus string
+
+const (
+	BroadcastPending   BroadcastStatus = "pending"
+	BroadcastRunning   BroadcastStatus = "running"
+	BroadcastCancelled BroadcastStatus = "cancelled"
+	BroadcastFinished  BroadcastStatus = "finished"
+)
+
+// Broadcast is the sending of a message to every user. ChatID and ProgressMessageID point to the
+// admin message showing the progress. LastUserID is the last user the message was sent to, users are
+// sent to in the order of their ID.
+type Broadcast struct {
+	ID                int             `json:"id"`
+	MessageID         int             `json:"message_id"`
+	Status            BroadcastStatus `json:"status"`
+	ChatID            int64           `json:"chat_id"`
+	ProgressMessageID int             `json:"progress_message_id"`
+	Total             int             `json:"total"`
+	Delivered         int             `json:"delivered"`
+	Blocked           int             `json:"blocked"`
+	Failed            int             `json:"failed"`
+	LastUserID        int64           `json:"last_user_id"`
+	CreatedAt         time.Time       `json:"created_at"`
+	StartedAt         *time.Time      `json:"started_at"`
+	FinishedAt        *time.Time      `json:"finished_at"`
+}
+
+// Processed returns the number of users the message was already sent to.
+func (b *Broadcast) Processed() int {
+	return b.Delivered + b.Blocked + b.Failed
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// DefaultCampaignID is the campaign of users who came without a campaign deep-link:
+// the main channel gated by every secondary channel.
+const DefaultCampaignID = 0
+
+type Campaign struct {
+	ID              int       `json:"id"`
+	Name            string    `json:"name"`
+	Slug            string    `json:"slug"`
+	TargetChannelID int       `json:"target_channel_id"`
+	CreatedAt       time.Time `json:"created_at"`
+
+	Target   *Channel  `json:"target,omitempty"`
+	Channels []Channel `json:"channels,omitempty"`
+}
+
+// StartPayload is the /start deep-link payload that brings a user to the campaign.
+func (c *Campaign) StartPayload() string {
+	return "c_" + c.Slug
+}
@@ -1,15 +1,14 @@
 package model
 
-import (
-	"strconv"
-	"strings"
-)
+import "time"
 
 type Status string
 
 var (
 	ChannelStatusMain      Status = "main"
 	ChannelStatusSecondary Status = "secondary"
+	// ChannelStatusPrivate channels are never offered to users, a demoted main channel becomes one.
+	ChannelStatusPrivate Status = "private"
 )
 
 type Channel struct {
@@ -18,18 +17,25 @@ type Channel struct {
 	Name              string `json:"name"`
 	URL               string `json:"url"`
 	ChannelStatus     Status `json:"channel_status"`
+
+	IsRequired      bool       `json:"is_required"`
+	AllowRestricted bool       `json:"allow_restricted"`
+	StartsAt        *time.Time `json:"starts_at"`
+	EndsAt          *time.Time `json:"ends_at"`
+	SortOrder       int        `json:"sort_order"`
 }
 
-func GetID(data string) int {
-	parts := strings.Split(data, "_")
-	if len(parts) > 3 {
-		return 0
+// RequiredAt reports whether the subscription to the channel is enforced at t.
+// Outside of its period a required channel is shown as optional.
+func (c Channel) RequiredAt(t time.Time) bool {
+	if !c.IsRequired {
+		return false
 	}
-
-	id, err := strconv.Atoi(parts[2])
-	if err != nil {
-		return 0
+	if c.StartsAt != nil && t.Before(*c.StartsAt) {
+		return false
 	}
-
-	return id
+	if c.EndsAt != nil && !t.Before(*c.EndsAt) {
+		return false
+	}
+	return true
 }
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// FunnelEvent is a step of the way from /start to the invite link.
+type FunnelEvent string
+
+const (
+	FunnelStart       FunnelEvent = "start"
+	FunnelSecondStep  FunnelEvent = "second_step"
+	FunnelReadyFailed FunnelEvent = "ready_failed"
+	FunnelLinkIssued  FunnelEvent = "link_issued"
+)
+
+// FunnelStats counts the users who reached every step of the funnel.
+type FunnelStats struct {
+	Start       int `json:"start"`
+	SecondStep  int `json:"second_step"`
+	ReadyFailed int `json:"ready_failed"`
+	LinkIssued  int `json:"link_issued"`
+}
+
+// DailyCount is the number of something that happened on Day.
+type DailyCount struct {
+	Day   time.Time `json:"day"`
+	Count int       `json:"count"`
+}
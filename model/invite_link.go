@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type InviteLink struct {
+	ID                 int        `json:"id"`
+	UserID             int64      `json:"user_id"`
+	ChannelID          int        `json:"channel_id"`
+	ChannelTelegramID  int64      `json:"channel_telegram_id"`
+	CampaignID         int        `json:"campaign_id"`
+	InviteLink         string     `json:"invite_link"`
+	CreatesJoinRequest bool       `json:"creates_join_request"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ExpiresAt          time.Time  `json:"expires_at"`
+	UsedAt             *time.Time `json:"used_at"`
+	RevokedAt          *time.Time `json:"revoked_at"`
+}
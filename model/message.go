@@ -1,9 +1,21 @@
 package model
 
+type FileType string
+
+const (
+	FileTypePhoto     FileType = "photo"
+	FileTypeVideo     FileType = "video"
+	FileTypeAnimation FileType = "animation"
+	FileTypeDocument  FileType = "document"
+)
+
+// Message is a broadcast or, when Key is set, an editable bot text.
 type Message struct {
-	ID         int     `json:"id"`
-	Message    *string `json:"message"`
-	FileID     *string `json:"file_id"`
-	ButtonUrl  *string `json:"button_url"`
-	ButtonText *string `json:"button_text"`
+	ID         int       `json:"id"`
+	Key        *string   `json:"key"`
+	Message    *string   `json:"message"`
+	FileID     *string   `json:"file_id"`
+	FileType   *FileType `json:"file_type"`
+	ButtonUrl  *string   `json:"button_url"`
+	ButtonText *string   `json:"button_text"`
 }
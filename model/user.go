@@ -2,9 +2,27 @@ package model
 
 import "time"
 
+// Roles of users, admins and superAdmins can use the admin panel.
+const (
+	RoleUser       = "user"
+	RoleAdmin      = "admin"
+	RoleSuperAdmin = "superAdmin"
+)
+
 type User struct {
 	ID         int64     `json:"id,omitempty"`
 	UsernameTg string    `json:"tg_username"`
 	CreatedAt  time.Time `json:"created_at,omitempty"`
 	Role       string    `json:"user_role"`
+	CampaignID *int      `json:"campaign_id,omitempty"`
+
+	// ReferrerID is the user whose link brought this one, the referral counts once ReferralConfirmedAt is set.
+	ReferrerID          *int64     `json:"referrer_id,omitempty"`
+	ReferralConfirmedAt *time.Time `json:"referral_confirmed_at,omitempty"`
+}
+
+// Referrer is a user with the number of confirmed referrals.
+type Referrer struct {
+	User      User `json:"user"`
+	Referrals int  `json:"referrals"`
 }
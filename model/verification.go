@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Verification is one evaluation of the subscription check when the user pressed "ГОТОВО".
+type Verification struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	CampaignID int       `json:"campaign_id"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Passed     bool      `json:"passed"`
+	LinkIssued bool      `json:"link_issued"`
+
+	Channels []VerificationChannel `json:"channels,omitempty"`
+}
+
+// VerificationChannel is the outcome of one channel of a verification. ChannelID is nil once the
+// channel is deleted, ChannelName keeps the name it had.
+type VerificationChannel struct {
+	ChannelID   *int   `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	Status      string `json:"status"`
+	Required    bool   `json:"required"`
+}
+
+// VerificationCounts counts users by the outcome of their latest verification.
+type VerificationCounts struct {
+	Users      int `json:"users"`
+	Passed     int `json:"passed"`
+	LinkIssued int `json:"link_issued"`
+}
+
+// ChannelFailures counts the verifications a channel failed: NotMember when the user was not
+// subscribed to it, Failed when it could not be checked.
+type ChannelFailures struct {
+	ChannelID   int    `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	NotMember   int    `json:"not_member"`
+	Failed      int    `json:"failed"`
+}
@@ -21,6 +21,11 @@ func (l *Logger) Fatal(format string, v ...any) {
 	l.sugarLogger.Fatalf(format, v...)
 }
 
+// Sync flushes buffered log entries.
+func (l *Logger) Sync() error {
+	return l.sugarLogger.Sync()
+}
+
 func New() *Logger {
 
 	config := zap.NewDevelopmentConfig()
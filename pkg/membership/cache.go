@@ -0,0 +1,71 @@
+package membership
+
+import (
+	"sync"
+	"time"
+)
+
+type key struct {
+	userID    int64
+	channelID int64
+}
+
+// Cache remembers that a user is a member of a channel for ttl. Only positive results are cached,
+// a user who just subscribed is never told they are missing a channel because of the cache.
+type Cache struct {
+	ttl time.Duration
+
+	mu      sync.RWMutex
+	entries map[key]time.Time
+	swept   time.Time
+}
+
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		entries: make(map[key]time.Time),
+	}
+}
+
+func (c *Cache) IsMember(userID, channelID int64) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	expiresAt, ok := c.entries[key{userID, channelID}]
+	return ok && time.Now().Before(expiresAt)
+}
+
+func (c *Cache) SetMember(userID, channelID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.entries[key{userID, channelID}] = now.Add(c.ttl)
+
+	if now.Sub(c.swept) > time.Minute {
+		c.swept = now
+		for k, expiresAt := range c.entries {
+			if now.After(expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+}
+
+func (c *Cache) Invalidate(userID, channelID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key{userID, channelID})
+}
+
+func (c *Cache) InvalidateUser(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k := range c.entries {
+		if k.userID == userID {
+			delete(c.entries, k)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package membership
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/logger"
+	"subscriber-check-bot/pkg/telegram"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusMember    Status = "member"
+	StatusNotMember Status = "not_member"
+	StatusFailed    Status = "failed"
+)
+
+// FailPolicy decides how channels that could not be checked count.
+type FailPolicy string
+
+const (
+	FailPolicyFail FailPolicy = "fail"
+	FailPolicyPass FailPolicy = "pass"
+)
+
+// Result is the outcome of checking one channel. Err is set when Status is StatusFailed.
+// Results of channels that are not Required are shown to the user but never fail the check.
+type Result struct {
+	Channel  model.Channel
+	Status   Status
+	Required bool
+	Err      error
+}
+
+// Report holds results in the order the channels were given.
+type Report struct {
+	Results []Result
+}
+
+// Missing returns required channels the user is not subscribed to.
+func (r Report) Missing() []model.Channel {
+	var channels []model.Channel
+	for _, el := range r.Results {
+		if el.Required && el.Status == StatusNotMember {
+			channels = append(channels, el.Channel)
+		}
+	}
+	return channels
+}
+
+// Failed returns results of channels that could not be checked.
+func (r Report) Failed() []Result {
+	var results []Result
+	for _, el := range r.Results {
+		if el.Status == StatusFailed {
+			results = append(results, el)
+		}
+	}
+	return results
+}
+
+// Passed reports whether the user passed the check, failed checks count according to policy.
+func (r Report) Passed(policy FailPolicy) bool {
+	for _, el := range r.Results {
+		if !el.Required {
+			continue
+		}
+
+		switch el.Status {
+		case StatusNotMember:
+			return false
+		case StatusFailed:
+			if policy != FailPolicyPass {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Checker tells whether a user is subscribed to channels.
+type Checker struct {
+	client      telegram.Client
+	cache       *Cache
+	log         *logger.Logger
+	concurrency int
+	policy      FailPolicy
+}
+
+func NewChecker(client telegram.Client, cache *Cache, log *logger.Logger, concurrency int, policy FailPolicy) *Checker {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	return &Checker{
+		client:      client,
+		cache:       cache,
+		log:         log,
+		concurrency: concurrency,
+		policy:      policy,
+	}
+}
+
+// Check checks every channel concurrently, at most concurrency at a time.
+func (c *Checker) Check(ctx context.Context, channels []model.Channel, userID int64) Report {
+	report := Report{Results: make([]Result, len(channels))}
+	now := time.Now()
+
+	sem := make(chan struct{}, c.concurrency)
+	var wg sync.WaitGroup
+
+	for i, el := range channels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			defer func() { report.Results[i].Required = el.RequiredAt(now) }()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				report.Results[i] = Result{Channel: el, Status: StatusFailed, Err: ctx.Err()}
+				return
+			}
+
+			report.Results[i] = c.checkChannel(el, userID)
+		}()
+	}
+
+	wg.Wait()
+	return report
+}
+
+// Passed reports whether report passes with the configured policy.
+func (c *Checker) Passed(report Report) bool {
+	return report.Passed(c.policy)
+}
+
+func (c *Checker) checkChannel(channel model.Channel, userID int64) Result {
+	if c.cache.IsMember(userID, channel.ChannelTelegramId) {
+		return Result{Channel: channel, Status: StatusMember}
+	}
+
+	cfg := tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
+			ChatID: channel.ChannelTelegramId,
+			UserID: userID,
+		},
+	}
+
+	chatMember, err := c.client.GetChatMember(cfg)
+	if err != nil {
+		c.log.Error("error with chatID = %d:%v", channel.ChannelTelegramId, err)
+		return Result{Channel: channel, Status: StatusFailed, Err: err}
+	}
+
+	switch chatMember.Status {
+	case "creator", "administrator", "member":
+		c.cache.SetMember(userID, channel.ChannelTelegramId)
+		return Result{Channel: channel, Status: StatusMember}
+	case "restricted":
+		// a restricted user may still be in the chat, it counts only if the channel allows it
+		if channel.AllowRestricted && chatMember.IsMember {
+			c.cache.SetMember(userID, channel.ChannelTelegramId)
+			return Result{Channel: channel, Status: StatusMember}
+		}
+		return Result{Channel: channel, Status: StatusNotMember}
+	default:
+		return Result{Channel: channel, Status: StatusNotMember}
+	}
+}
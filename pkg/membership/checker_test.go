@@ -0,0 +1,44 @@
+package membership
+
+import (
+	"subscriber-check-bot/model"
+	"testing"
+)
+
+func TestReportPassed(t *testing.T) {
+	result := func(id int, status Status, required bool) Result {
+		return Result{Channel: model.Channel{ID: id}, Status: status, Required: required}
+	}
+
+	tests := []struct {
+		name        string
+		results     []Result
+		wantFail    bool
+		wantPass    bool
+		wantMissing int
+	}{
+		{"no channels", nil, true, true, 0},
+		{"member of every channel", []Result{result(1, StatusMember, true), result(2, StatusMember, true)}, true, true, 0},
+		{"missing a required channel", []Result{result(1, StatusMember, true), result(2, StatusNotMember, true)}, false, false, 1},
+		{"missing an optional channel", []Result{result(1, StatusMember, true), result(2, StatusNotMember, false)}, true, true, 0},
+		{"required channel not checked", []Result{result(1, StatusMember, true), result(2, StatusFailed, true)}, false, true, 0},
+		{"optional channel not checked", []Result{result(1, StatusMember, true), result(2, StatusFailed, false)}, true, true, 0},
+		{"missing and not checked", []Result{result(1, StatusNotMember, true), result(2, StatusFailed, true)}, false, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Report{Results: tt.results}
+
+			if got := report.Passed(FailPolicyFail); got != tt.wantFail {
+				t.Errorf("Passed(%s) = %t, want %t", FailPolicyFail, got, tt.wantFail)
+			}
+			if got := report.Passed(FailPolicyPass); got != tt.wantPass {
+				t.Errorf("Passed(%s) = %t, want %t", FailPolicyPass, got, tt.wantPass)
+			}
+			if got := len(report.Missing()); got != tt.wantMissing {
+				t.Errorf("Missing = %d channels, want %d", got, tt.wantMissing)
+			}
+		})
+	}
+}
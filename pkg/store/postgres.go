@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/pkg/postgres"
+	"time"
+)
+
+type postgresStore struct {
+	*postgres.Postgres
+	ttl time.Duration
+}
+
+// NewPostgresStore returns a Store kept in the conversation_state table, it survives restarts.
+func NewPostgresStore(pg *postgres.Postgres, ttl time.Duration) Store {
+	return &postgresStore{
+		Postgres: pg,
+		ttl:      ttl,
+	}
+}
+
+func (s *postgresStore) Set(ctx context.Context, data State, userID int64) error {
+	return s.SetWithTTL(ctx, data, userID, s.ttl)
+}
+
+func (s *postgresStore) SetWithTTL(ctx context.Context, data State, userID int64, ttl time.Duration) error {
+	query := `insert into conversation_state (user_id, kind, data, expires_at) values ($1,$2,$3,$4)
+		on conflict (user_id) do update set kind = excluded.kind, data = excluded.data, expires_at = excluded.expires_at`
+
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Pool.Exec(ctx, query, userID, data.Kind(), dataByte, time.Now().Add(ttl))
+	return err
+}
+
+func (s *postgresStore) Read(ctx context.Context, userID int64) (State, bool, error) {
+	query := `select kind, data from conversation_state where user_id = $1 and expires_at > $2`
+
+	var (
+		kind string
+		data []byte
+	)
+
+	err := s.Pool.QueryRow(ctx, query, userID, time.Now()).Scan(&kind, &data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	state, err := decode(kind, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return state, true, nil
+}
+
+func (s *postgresStore) Delete(ctx context.Context, userID int64) error {
+	query := `delete from conversation_state where user_id = $1`
+
+	_, err := s.Pool.Exec(ctx, query, userID)
+	return err
+}
+
+func (s *postgresStore) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `delete from conversation_state where expires_at <= $1`
+
+	tag, err := s.Pool.Exec(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -1,6 +1,13 @@
 package store
 
-import "sync"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
 
 type TypeCommand string
 
@@ -9,6 +16,14 @@ var (
 	UserAdminDelete TypeCommand = "delete"
 )
 
+var ErrUnknownKind = errors.New("unknown state kind")
+
+// State is the step of a conversation kept for a user between updates.
+// Every State must be registered with Register to be read back from a persistent store.
+type State interface {
+	Kind() string
+}
+
 type AdminStore struct {
 	MsgID  int64
 	UserID int64
@@ -16,38 +31,204 @@ type AdminStore struct {
 	TypeCommand TypeCommand
 }
 
-type Store struct {
-	store map[int64]interface{}
+func (AdminStore) Kind() string { return "admin" }
+
+// CampaignStore is the campaign an admin is creating: the name is asked first, then the target channel.
+type CampaignStore struct {
+	Name string
+}
+
+func (CampaignStore) Kind() string { return "campaign" }
+
+// CaptchaStore is the challenge a user solves before getting the invite link. BlockedUntil is set
+// once the user ran out of attempts.
+type CaptchaStore struct {
+	CampaignID   int
+	Answer       int
+	Attempts     int
+	BlockedUntil time.Time
+}
+
+func (CaptchaStore) Kind() string { return "captcha" }
+
+type BroadcastStep string
+
+const (
+	BroadcastStepMessage BroadcastStep = "message"
+	BroadcastStepButton  BroadcastStep = "button"
+)
+
+// BroadcastStore is the broadcast an admin is composing, MessageID is set once the message is saved.
+type BroadcastStore struct {
+	Step      BroadcastStep
+	MessageID int
+}
+
+func (BroadcastStore) Kind() string { return "broadcast" }
+
+type TextField string
+
+const (
+	TextFieldText   TextField = "text"
+	TextFieldMedia  TextField = "media"
+	TextFieldButton TextField = "button"
+)
+
+// TextStore is the part of a bot text an admin is editing.
+type TextStore struct {
+	Key   string
+	Field TextField
+}
+
+func (TextStore) Kind() string { return "text" }
+
+type ChannelRuleField string
+
+const (
+	ChannelRulePeriod ChannelRuleField = "period"
+	ChannelRuleOrder  ChannelRuleField = "order"
+)
+
+// ChannelRuleStore is the channel rule an admin is editing by message.
+type ChannelRuleStore struct {
+	ChannelID int
+	Field     ChannelRuleField
+}
+
+func (ChannelRuleStore) Kind() string { return "channel_rule" }
+
+// ChannelRenameStore is the channel an admin is renaming.
+type ChannelRenameStore struct {
+	ChannelID int
+}
+
+func (ChannelRenameStore) Kind() string { return "channel_rename" }
+
+// VerificationStore is an admin looking up the latest verification of a user.
+type VerificationStore struct{}
+
+func (VerificationStore) Kind() string { return "verification" }
+
+func init() {
+	Register[AdminStore]()
+	Register[CampaignStore]()
+	Register[ChannelRuleStore]()
+	Register[ChannelRenameStore]()
+	Register[CaptchaStore]()
+	Register[BroadcastStore]()
+	Register[TextStore]()
+	Register[VerificationStore]()
+}
+
+// Store keeps one State per user. States expire after the store TTL unless set with SetWithTTL.
+type Store interface {
+	Set(ctx context.Context, data State, userID int64) error
+	SetWithTTL(ctx context.Context, data State, userID int64, ttl time.Duration) error
+	Read(ctx context.Context, userID int64) (State, bool, error)
+	Delete(ctx context.Context, userID int64) error
+
+	// DeleteExpired removes expired states and returns how many were removed.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+var (
+	kindsMu sync.RWMutex
+	kinds   = make(map[string]func(data []byte) (State, error))
+)
+
+// Register makes T decodable by kind.
+func Register[T State]() {
+	var zero T
+
+	kindsMu.Lock()
+	defer kindsMu.Unlock()
+
+	kinds[zero.Kind()] = func(data []byte) (State, error) {
+		var state T
+		err := json.Unmarshal(data, &state)
+		return state, err
+	}
+}
+
+func decode(kind string, data []byte) (State, error) {
+	kindsMu.RLock()
+	decodeFn, ok := kinds[kind]
+	kindsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	}
+
+	return decodeFn(data)
+}
+
+type entry struct {
+	data      State
+	expiresAt time.Time
+}
+
+type memoryStore struct {
+	store map[int64]entry
+	ttl   time.Duration
 
 	mu sync.RWMutex
 }
 
-func NewStore() *Store {
-	return &Store{
-		store: make(map[int64]interface{}, 15),
+// NewMemoryStore returns a Store living in process memory, it is lost on restart.
+func NewMemoryStore(ttl time.Duration) Store {
+	return &memoryStore{
+		store: make(map[int64]entry, 15),
+		ttl:   ttl,
 	}
 }
 
-func (s *Store) Set(data interface{}, userID int64) {
+func (s *memoryStore) Set(ctx context.Context, data State, userID int64) error {
+	return s.SetWithTTL(ctx, data, userID, s.ttl)
+}
+
+func (s *memoryStore) SetWithTTL(_ context.Context, data State, userID int64, ttl time.Duration) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.store[userID] = data
+
+	s.store[userID] = entry{
+		data:      data,
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
 }
 
-func (s *Store) Read(userID int64) (interface{}, bool) {
+func (s *memoryStore) Read(_ context.Context, userID int64) (State, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	d, ok := s.store[userID]
-	if !ok {
-		return nil, false
+	e, ok := s.store[userID]
+	if !ok || time.Now().After(e.expiresAt) {
+		return nil, false, nil
 	}
 
-	return d, true
+	return e.data, true, nil
 }
 
-func (s *Store) Delete(userID int64) {
+func (s *memoryStore) Delete(_ context.Context, userID int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+
 	delete(s.store, userID)
+	return nil
+}
+
+func (s *memoryStore) DeleteExpired(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for userID, e := range s.store {
+		if now.After(e.expiresAt) {
+			delete(s.store, userID)
+			deleted++
+		}
+	}
+
+	return deleted, nil
 }
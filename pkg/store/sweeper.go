@@ -0,0 +1,29 @@
+package store
+
+import (
+	"context"
+	"subscriber-check-bot/pkg/logger"
+	"time"
+)
+
+// RunSweeper deletes expired states every interval until ctx is done.
+func RunSweeper(ctx context.Context, s Store, interval time.Duration, log *logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			deleted, err := s.DeleteExpired(ctx)
+			if err != nil {
+				log.Error("store sweeper: DeleteExpired: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Info("store sweeper: deleted %d expired states", deleted)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package telegram
+
+import (
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Client is the part of the Telegram Bot API the bot depends on.
+type Client interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error)
+
+	GetChat(config tgbotapi.ChatInfoConfig) (tgbotapi.Chat, error)
+	GetChatMember(config tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error)
+	CreateChatInviteLink(config tgbotapi.CreateChatInviteLinkConfig) (tgbotapi.ChatInviteLink, error)
+
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	StopReceivingUpdates()
+}
+
+var _ Client = (*API)(nil)
+
+// API is the Client backed by the real Bot API.
+type API struct {
+	*tgbotapi.BotAPI
+}
+
+func New(bot *tgbotapi.BotAPI) *API {
+	return &API{
+		bot,
+	}
+}
+
+func (a *API) CreateChatInviteLink(config tgbotapi.CreateChatInviteLinkConfig) (tgbotapi.ChatInviteLink, error) {
+	var link tgbotapi.ChatInviteLink
+
+	response, err := a.Request(config)
+	if err != nil {
+		return link, err
+	}
+
+	err = json.Unmarshal(response.Result, &link)
+	return link, err
+}
@@ -0,0 +1,195 @@
+package fake
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"subscriber-check-bot/pkg/telegram"
+	"sync"
+)
+
+var _ telegram.Client = (*Client)(nil)
+
+var ErrChatNotFound = errors.New("Bad Request: chat not found")
+
+// Client is an in-memory telegram.Client. It records every outgoing call and answers
+// membership requests from the statuses set with SetMember, so handlers can run offline.
+type Client struct {
+	mu sync.Mutex
+
+	sent     []tgbotapi.Chattable
+	requests []tgbotapi.Chattable
+	raw      []string
+
+	chats   map[int64]tgbotapi.Chat
+	members map[int64]map[int64]string
+	errs    map[string]error
+
+	messageID  int
+	inviteLink int
+
+	updates chan tgbotapi.Update
+}
+
+func New() *Client {
+	return &Client{
+		chats:   make(map[int64]tgbotapi.Chat),
+		members: make(map[int64]map[int64]string),
+		errs:    make(map[string]error),
+		updates: make(chan tgbotapi.Update, 100),
+	}
+}
+
+// AddChat makes chat known to GetChat.
+func (c *Client) AddChat(chat tgbotapi.Chat) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.chats[chat.ID] = chat
+}
+
+// SetMember sets the status GetChatMember returns for userID in chatID.
+func (c *Client) SetMember(chatID, userID int64, status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.members[chatID] == nil {
+		c.members[chatID] = make(map[int64]string)
+	}
+	c.members[chatID][userID] = status
+}
+
+// FailOn makes every call of method (e.g. "Send", "GetChatMember") return err.
+func (c *Client) FailOn(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.errs[method] = err
+}
+
+// Push queues update for the channel returned by GetUpdatesChan.
+func (c *Client) Push(update tgbotapi.Update) {
+	c.updates <- update
+}
+
+// Sent returns everything passed to Send, in order.
+func (c *Client) Sent() []tgbotapi.Chattable {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]tgbotapi.Chattable(nil), c.sent...)
+}
+
+// Requests returns everything passed to Request, in order.
+func (c *Client) Requests() []tgbotapi.Chattable {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]tgbotapi.Chattable(nil), c.requests...)
+}
+
+// RawRequests returns endpoints called through MakeRequest, in order.
+func (c *Client) RawRequests() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.raw...)
+}
+
+func (c *Client) Send(chattable tgbotapi.Chattable) (tgbotapi.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sent = append(c.sent, chattable)
+	if err := c.errs["Send"]; err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	c.messageID++
+	return tgbotapi.Message{MessageID: c.messageID}, nil
+}
+
+func (c *Client) Request(chattable tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, chattable)
+	if err := c.errs["Request"]; err != nil {
+		return nil, err
+	}
+
+	return &tgbotapi.APIResponse{Ok: true, Result: []byte("true")}, nil
+}
+
+func (c *Client) MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.raw = append(c.raw, endpoint)
+	if err := c.errs["MakeRequest"]; err != nil {
+		return nil, err
+	}
+
+	return &tgbotapi.APIResponse{Ok: true, Result: []byte("true")}, nil
+}
+
+func (c *Client) GetChat(config tgbotapi.ChatInfoConfig) (tgbotapi.Chat, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, config)
+	if err := c.errs["GetChat"]; err != nil {
+		return tgbotapi.Chat{}, err
+	}
+
+	chat, ok := c.chats[config.ChatID]
+	if !ok {
+		return tgbotapi.Chat{}, ErrChatNotFound
+	}
+
+	return chat, nil
+}
+
+func (c *Client) GetChatMember(config tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, config)
+	if err := c.errs["GetChatMember"]; err != nil {
+		return tgbotapi.ChatMember{}, err
+	}
+
+	status, ok := c.members[config.ChatID][config.UserID]
+	if !ok {
+		status = "left"
+	}
+
+	return tgbotapi.ChatMember{
+		User:   &tgbotapi.User{ID: config.UserID},
+		Status: status,
+	}, nil
+}
+
+func (c *Client) CreateChatInviteLink(config tgbotapi.CreateChatInviteLinkConfig) (tgbotapi.ChatInviteLink, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, config)
+	if err := c.errs["CreateChatInviteLink"]; err != nil {
+		return tgbotapi.ChatInviteLink{}, err
+	}
+
+	c.inviteLink++
+	return tgbotapi.ChatInviteLink{
+		InviteLink:         fmt.Sprintf("https://t.me/+fake%d", c.inviteLink),
+		CreatesJoinRequest: config.CreatesJoinRequest,
+		ExpireDate:         config.ExpireDate,
+		MemberLimit:        config.MemberLimit,
+	}, nil
+}
+
+func (c *Client) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	return c.updates
+}
+
+func (c *Client) StopReceivingUpdates() {}
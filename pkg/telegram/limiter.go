@@ -0,0 +1,293 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"sync"
+	"time"
+)
+
+// Limits configures the limited client. Telegram allows about 30 messages per second in total,
+// one message per second to a private chat and 20 messages per minute to a group or channel.
+type Limits struct {
+	PerSecond     int
+	ChatInterval  time.Duration
+	GroupInterval time.Duration
+
+	MaxRetries    int
+	MaxRetryAfter time.Duration
+}
+
+// chatBurst is how many messages a chat may get at once before the interval applies,
+// so a view sending two messages in a row isn't delayed.
+const chatBurst = 3
+
+// bucket is a token bucket refilled with one token every interval up to burst tokens.
+type bucket struct {
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+func newBucket(interval time.Duration, burst int) *bucket {
+	return &bucket{
+		interval: interval,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+	}
+}
+
+// reserve takes a token and returns how long to wait until it is actually available.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if !b.last.IsZero() && b.interval > 0 {
+		b.tokens = min(b.burst, b.tokens+float64(now.Sub(b.last))/float64(b.interval))
+	}
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 || b.interval <= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.interval))
+}
+
+func (b *bucket) idle(now time.Time) bool {
+	return now.Sub(b.last) > time.Duration(b.burst)*b.interval
+}
+
+type limitedClient struct {
+	Client
+	limits Limits
+	ctx    context.Context
+
+	mu     sync.Mutex
+	global *bucket
+	chats  map[int64]*bucket
+	swept  time.Time
+}
+
+var _ Client = (*limitedClient)(nil)
+
+// NewLimited wraps client so that sent and edited messages respect limits, other calls such as
+// GetChatMember aren't messages and don't take a slot. Calls answered with 429 are retried
+// after retry_after. Calls answered with 5xx are retried with exponential backoff unless repeating them
+// could do the work twice, a new message may already be delivered when Telegram fails. Once ctx is done
+// waiting calls return its error, so shutdown isn't held up by the limits.
+func NewLimited(ctx context.Context, client Client, limits Limits) Client {
+	return &limitedClient{
+		Client: client,
+		limits: limits,
+		ctx:    ctx,
+		global: newBucket(time.Second/time.Duration(max(limits.PerSecond, 1)), max(limits.PerSecond, 1)),
+		chats:  make(map[int64]*bucket),
+	}
+}
+
+func (l *limitedClient) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var msg tgbotapi.Message
+
+	err := l.do(chatID(c), true, isEdit(c), func() error {
+		var err error
+		msg, err = l.Client.Send(c)
+		return err
+	})
+	return msg, err
+}
+
+func (l *limitedClient) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	var resp *tgbotapi.APIResponse
+
+	id := chatID(c)
+
+	err := l.do(id, id != 0, true, func() error {
+		var err error
+		resp, err = l.Client.Request(c)
+		return err
+	})
+	return resp, err
+}
+
+func (l *limitedClient) MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	var resp *tgbotapi.APIResponse
+
+	err := l.do(0, false, false, func() error {
+		var err error
+		resp, err = l.Client.MakeRequest(endpoint, params)
+		return err
+	})
+	return resp, err
+}
+
+func (l *limitedClient) GetChat(config tgbotapi.ChatInfoConfig) (tgbotapi.Chat, error) {
+	var chat tgbotapi.Chat
+
+	err := l.do(0, false, true, func() error {
+		var err error
+		chat, err = l.Client.GetChat(config)
+		return err
+	})
+	return chat, err
+}
+
+func (l *limitedClient) GetChatMember(config tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error) {
+	var member tgbotapi.ChatMember
+
+	err := l.do(0, false, true, func() error {
+		var err error
+		member, err = l.Client.GetChatMember(config)
+		return err
+	})
+	return member, err
+}
+
+func (l *limitedClient) CreateChatInviteLink(config tgbotapi.CreateChatInviteLinkConfig) (tgbotapi.ChatInviteLink, error) {
+	var link tgbotapi.ChatInviteLink
+
+	err := l.do(0, false, false, func() error {
+		var err error
+		link, err = l.Client.CreateChatInviteLink(config)
+		return err
+	})
+	return link, err
+}
+
+// do calls fn, retrying it while Telegram asks to. A limited call waits for a slot first, only an
+// idempotent call is retried on 5xx.
+func (l *limitedClient) do(chatID int64, limited, idempotent bool, fn func() error) error {
+	backoff := time.Second
+
+	for attempt := 0; ; attempt++ {
+		if limited {
+			if err := l.sleep(l.reserve(chatID)); err != nil {
+				return err
+			}
+		}
+
+		err := fn()
+		if err == nil || attempt >= l.limits.MaxRetries {
+			return err
+		}
+
+		var apiErr *tgbotapi.Error
+		if !errors.As(err, &apiErr) {
+			return err
+		}
+
+		switch {
+		case apiErr.RetryAfter > 0:
+			retryAfter := time.Duration(apiErr.RetryAfter) * time.Second
+			if retryAfter > l.limits.MaxRetryAfter {
+				return err
+			}
+			if err := l.sleep(retryAfter); err != nil {
+				return err
+			}
+		case apiErr.Code >= 500 && idempotent:
+			if err := l.sleep(backoff); err != nil {
+				return err
+			}
+			backoff *= 2
+		default:
+			return err
+		}
+	}
+}
+
+// sleep waits for d unless the client context is done first. Calls that don't have to wait still go
+// through after ctx is done, so handlers finishing during shutdown can answer.
+func (l *limitedClient) sleep(d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-l.ctx.Done():
+		return l.ctx.Err()
+	}
+}
+
+// reserve returns how long to wait before the next call to chatID, 0 means no chat.
+func (l *limitedClient) reserve(chatID int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	wait := l.global.reserve(now)
+
+	if chatID == 0 {
+		return wait
+	}
+
+	l.sweep(now)
+
+	chat, ok := l.chats[chatID]
+	if !ok {
+		interval := l.limits.ChatInterval
+		if chatID < 0 {
+			interval = l.limits.GroupInterval
+		}
+		chat = newBucket(interval, chatBurst)
+		l.chats[chatID] = chat
+	}
+
+	return max(wait, chat.reserve(now))
+}
+
+// sweep forgets chats that have been idle for a while, so the map doesn't grow with every user.
+func (l *limitedClient) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+
+	for id, chat := range l.chats {
+		if chat.idle(now) {
+			delete(l.chats, id)
+		}
+	}
+}
+
+func chatID(c tgbotapi.Chattable) int64 {
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		return v.ChatID
+	case tgbotapi.PhotoConfig:
+		return v.ChatID
+	case tgbotapi.VideoConfig:
+		return v.ChatID
+	case tgbotapi.DocumentConfig:
+		return v.ChatID
+	case tgbotapi.AnimationConfig:
+		return v.ChatID
+	case tgbotapi.CopyMessageConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageCaptionConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageMediaConfig:
+		return v.ChatID
+	default:
+		return 0
+	}
+}
+
+// isEdit reports whether c changes an existing message, doing that twice leaves the same message.
+func isEdit(c tgbotapi.Chattable) bool {
+	switch c.(type) {
+	case tgbotapi.EditMessageTextConfig, tgbotapi.EditMessageReplyMarkupConfig, tgbotapi.EditMessageCaptionConfig,
+		tgbotapi.EditMessageMediaConfig, tgbotapi.DeleteMessageConfig:
+		return true
+	default:
+		return false
+	}
+}
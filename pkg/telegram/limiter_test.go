@@ -0,0 +1,161 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"testing"
+	"time"
+)
+
+func TestBucketReserve(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		burst    int
+		calls    []time.Duration // offsets from start
+		want     []time.Duration
+	}{
+		{
+			name:     "burst is free",
+			interval: time.Second,
+			burst:    3,
+			calls:    []time.Duration{0, 0, 0},
+			want:     []time.Duration{0, 0, 0},
+		},
+		{
+			name:     "calls over the burst wait for the interval",
+			interval: time.Second,
+			burst:    2,
+			calls:    []time.Duration{0, 0, 0, 0},
+			want:     []time.Duration{0, 0, time.Second, 2 * time.Second},
+		},
+		{
+			name:     "tokens refill over time",
+			interval: time.Second,
+			burst:    1,
+			calls:    []time.Duration{0, 500 * time.Millisecond, 3 * time.Second},
+			want:     []time.Duration{0, 500 * time.Millisecond, 0},
+		},
+		{
+			name:     "refill is capped at the burst",
+			interval: time.Second,
+			burst:    2,
+			calls:    []time.Duration{0, time.Minute, time.Minute, time.Minute},
+			want:     []time.Duration{0, 0, 0, time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBucket(tt.interval, tt.burst)
+			for i, el := range tt.calls {
+				if got := b.reserve(start.Add(el)); got != tt.want[i] {
+					t.Fatalf("call %d waits %s, want %s", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChatID(t *testing.T) {
+	tests := []struct {
+		name string
+		c    tgbotapi.Chattable
+		want int64
+	}{
+		{"message", tgbotapi.NewMessage(5, "hi"), 5},
+		{"edit text", tgbotapi.NewEditMessageText(6, 1, "hi"), 6},
+		{"edit media", tgbotapi.EditMessageMediaConfig{BaseEdit: tgbotapi.BaseEdit{ChatID: 7, MessageID: 1}}, 7},
+		{"callback answer", tgbotapi.NewCallback("1", "ok"), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chatID(tt.c); got != tt.want {
+				t.Fatalf("chatID = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// scriptedClient answers Send with errs in turn, then succeeds.
+type scriptedClient struct {
+	Client
+	errs  []error
+	calls int
+}
+
+func (c *scriptedClient) Send(tgbotapi.Chattable) (tgbotapi.Message, error) {
+	c.calls++
+	if c.calls <= len(c.errs) {
+		return tgbotapi.Message{}, c.errs[c.calls-1]
+	}
+	return tgbotapi.Message{MessageID: c.calls}, nil
+}
+
+func TestLimitedClientRetries(t *testing.T) {
+	tooMany := &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}
+	tooManyLong := &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 60}}
+	badGateway := &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}
+	badRequest := &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}
+
+	message := tgbotapi.NewMessage(5, "hi")
+	edit := tgbotapi.NewEditMessageText(5, 1, "hi")
+
+	tests := []struct {
+		name      string
+		c         tgbotapi.Chattable
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"429 is retried after retry_after", message, []error{tooMany}, 2, nil},
+		{"429 waiting too long is returned", message, []error{tooManyLong}, 1, tooManyLong},
+		{"5xx of an edit is retried", edit, []error{badGateway}, 2, nil},
+		{"5xx of a new message is not retried", message, []error{badGateway}, 1, badGateway},
+		{"4xx is not retried", message, []error{badRequest}, 1, badRequest},
+		{"retries are limited", edit, []error{badGateway, badGateway, badGateway}, 2, badGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &scriptedClient{errs: tt.errs}
+			limited := NewLimited(context.Background(), client, Limits{
+				PerSecond:     30,
+				ChatInterval:  time.Millisecond,
+				GroupInterval: time.Millisecond,
+				MaxRetries:    1,
+				MaxRetryAfter: 30 * time.Second,
+			})
+
+			_, err := limited.Send(tt.c)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Send error = %v, want %v", err, tt.wantErr)
+			}
+			if client.calls != tt.wantCalls {
+				t.Fatalf("Send called %d times, want %d", client.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLimitedClientStopsWaitingOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &scriptedClient{errs: []error{&tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 20}}}}
+	limited := NewLimited(ctx, client, Limits{PerSecond: 30, MaxRetries: 3, MaxRetryAfter: time.Minute})
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := limited.Send(tgbotapi.NewMessage(5, "hi")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Send error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Send returned after %s, want right after the shutdown", elapsed)
+	}
+}
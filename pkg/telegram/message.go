@@ -0,0 +1,66 @@
+package telegram
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"subscriber-check-bot/model"
+)
+
+// NewMessage renders message for chatID: a media message with the text as caption or a text message.
+// The keyboard has rows followed by the URL button of the message if it has one.
+func NewMessage(chatID int64, message *model.Message, rows ...[]tgbotapi.InlineKeyboardButton) tgbotapi.Chattable {
+	var text string
+	if message.Message != nil {
+		text = *message.Message
+	}
+
+	var markup any
+	if keyboard := Keyboard(message, rows...); keyboard != nil {
+		markup = *keyboard
+	}
+
+	if message.FileID == nil || message.FileType == nil {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = markup
+		return msg
+	}
+
+	file := tgbotapi.FileID(*message.FileID)
+
+	switch *message.FileType {
+	case model.FileTypePhoto:
+		msg := tgbotapi.NewPhoto(chatID, file)
+		msg.Caption = text
+		msg.ReplyMarkup = markup
+		return msg
+	case model.FileTypeVideo:
+		msg := tgbotapi.NewVideo(chatID, file)
+		msg.Caption = text
+		msg.ReplyMarkup = markup
+		return msg
+	case model.FileTypeAnimation:
+		msg := tgbotapi.NewAnimation(chatID, file)
+		msg.Caption = text
+		msg.ReplyMarkup = markup
+		return msg
+	default:
+		msg := tgbotapi.NewDocument(chatID, file)
+		msg.Caption = text
+		msg.ReplyMarkup = markup
+		return msg
+	}
+}
+
+// Keyboard returns rows followed by the URL button of message, nil if there are no buttons at all.
+func Keyboard(message *model.Message, rows ...[]tgbotapi.InlineKeyboardButton) *tgbotapi.InlineKeyboardMarkup {
+	if message.ButtonText != nil && message.ButtonUrl != nil {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(*message.ButtonText, *message.ButtonUrl),
+		))
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &markup
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrPoolClosed = errors.New("worker pool is closed")
+
+// Pool runs tasks on a fixed number of workers. Tasks submitted with the same
+// key always land on the same worker, so they are executed in submission order.
+type Pool struct {
+	queues []chan func()
+	done   chan struct{}
+
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+}
+
+func New(count, queueSize int) *Pool {
+	if count <= 0 {
+		count = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &Pool{
+		queues: make([]chan func(), count),
+		done:   make(chan struct{}),
+	}
+
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), queueSize)
+
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+
+	return p
+}
+
+func (p *Pool) work(queue chan func()) {
+	defer p.wg.Done()
+
+	for task := range queue {
+		task()
+	}
+}
+
+// Submit puts task into the queue of the worker owning key. When that queue is
+// full Submit blocks until there is room or ctx is done, nothing is dropped.
+func (p *Pool) Submit(ctx context.Context, key int64, task func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	queue := p.queues[uint64(key)%uint64(len(p.queues))]
+
+	select {
+	case queue <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting new tasks and waits until every queued task is done.
+func (p *Pool) Close() {
+	p.stop()
+	<-p.done
+}
+
+// Shutdown is Close bounded by ctx. It returns ctx.Err() if tasks are still running when ctx is done,
+// in that case it may be called again to keep waiting.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.stop()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+
+	go func() {
+		p.wg.Wait()
+		close(p.done)
+	}()
+}
@@ -0,0 +1,158 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolKeepsOrderPerKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		workers   int
+		queueSize int
+		keys      int
+		tasks     int
+	}{
+		{"one worker", 1, 0, 3, 50},
+		{"more keys than workers", 4, 2, 16, 50},
+		{"more workers than keys", 8, 1, 3, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := New(tt.workers, tt.queueSize)
+
+			var mu sync.Mutex
+			got := make(map[int64][]int)
+
+			for i := 0; i < tt.tasks; i++ {
+				for key := int64(0); key < int64(tt.keys); key++ {
+					if err := pool.Submit(context.Background(), key, func() {
+						mu.Lock()
+						defer mu.Unlock()
+						got[key] = append(got[key], i)
+					}); err != nil {
+						t.Fatalf("Submit: %v", err)
+					}
+				}
+			}
+			pool.Close()
+
+			for key := int64(0); key < int64(tt.keys); key++ {
+				if len(got[key]) != tt.tasks {
+					t.Fatalf("key %d ran %d tasks, want %d", key, len(got[key]), tt.tasks)
+				}
+				for i, el := range got[key] {
+					if el != i {
+						t.Fatalf("key %d ran task %d at position %d", key, el, i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPoolSubmitBlocksWhenQueueIsFull(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueSize int
+	}{
+		{"unbuffered", 0},
+		{"buffered", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := New(1, tt.queueSize)
+			defer pool.Close()
+
+			release := make(chan struct{})
+			started := make(chan struct{})
+			if err := pool.Submit(context.Background(), 1, func() {
+				close(started)
+				<-release
+			}); err != nil {
+				t.Fatalf("Submit: %v", err)
+			}
+			<-started
+
+			for i := 0; i < tt.queueSize; i++ {
+				if err := pool.Submit(context.Background(), 1, func() {}); err != nil {
+					t.Fatalf("Submit %d: %v", i, err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if err := pool.Submit(ctx, 1, func() {}); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Submit to a full queue = %v, want %v", err, context.DeadlineExceeded)
+			}
+
+			close(release)
+			if err := pool.Submit(context.Background(), 1, func() {}); err != nil {
+				t.Fatalf("Submit after the queue drained: %v", err)
+			}
+		})
+	}
+}
+
+func TestPoolShutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		grace    time.Duration
+		wantErr  error
+	}{
+		{"tasks finish within the grace period", 10 * time.Millisecond, time.Second, nil},
+		{"tasks outlive the grace period", time.Second, 20 * time.Millisecond, context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := New(2, 4)
+
+			release := make(chan struct{})
+			var mu sync.Mutex
+			var done int
+
+			for key := int64(0); key < 4; key++ {
+				if err := pool.Submit(context.Background(), key, func() {
+					select {
+					case <-time.After(tt.duration):
+					case <-release:
+					}
+					mu.Lock()
+					done++
+					mu.Unlock()
+				}); err != nil {
+					t.Fatalf("Submit: %v", err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.grace)
+			defer cancel()
+			if err := pool.Shutdown(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Shutdown = %v, want %v", err, tt.wantErr)
+			}
+
+			if err := pool.Submit(context.Background(), 1, func() {}); !errors.Is(err, ErrPoolClosed) {
+				t.Fatalf("Submit after Shutdown = %v, want %v", err, ErrPoolClosed)
+			}
+
+			// a second Shutdown keeps waiting for the queued tasks
+			close(release)
+			if err := pool.Shutdown(context.Background()); err != nil {
+				t.Fatalf("second Shutdown: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if done != 4 {
+				t.Fatalf("%d tasks done, want 4", done)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/postgres"
+	"time"
+)
+
+type AccessWarningRepo interface {
+	Get(ctx context.Context, userID int64, channelID int) (*model.AccessWarning, error)
+
+	Create(ctx context.Context, warning *model.AccessWarning) error
+
+	Delete(ctx context.Context, userID int64, channelID int) error
+
+	MarkRemoved(ctx context.Context, userID int64, channelID int, removedAt time.Time) error
+}
+
+type accessWarningRepo struct {
+	*postgres.Postgres
+}
+
+func NewAccessWarningRepo(pg *postgres.Postgres) AccessWarningRepo {
+	return &accessWarningRepo{
+		pg,
+	}
+}
+
+func (a *accessWarningRepo) collectRow(row pgx.Row) (*model.AccessWarning, error) {
+	var warning model.AccessWarning
+	err := row.Scan(&warning.UserID, &warning.ChannelID, &warning.WarnedAt, &warning.RemovedAt)
+
+	return &warning, err
+}
+
+func (a *accessWarningRepo) Get(ctx context.Context, userID int64, channelID int) (*model.AccessWarning, error) {
+	query := `select user_id, channel_id, warned_at, removed_at from access_warning where user_id = $1 and channel_id = $2`
+
+	row := a.Pool.QueryRow(ctx, query, userID, channelID)
+	return a.collectRow(row)
+}
+
+func (a *accessWarningRepo) Create(ctx context.Context, warning *model.AccessWarning) error {
+	query := `insert into access_warning (user_id, channel_id, warned_at) values ($1,$2,$3)
+		on conflict (user_id, channel_id) do update set warned_at = excluded.warned_at, removed_at = null`
+
+	_, err := a.Pool.Exec(ctx, query, warning.UserID, warning.ChannelID, warning.WarnedAt)
+	return err
+}
+
+func (a *accessWarningRepo) Delete(ctx context.Context, userID int64, channelID int) error {
+	query := `delete from access_warning where user_id = $1 and channel_id = $2`
+
+	_, err := a.Pool.Exec(ctx, query, userID, channelID)
+	return err
+}
+
+func (a *accessWarningRepo) MarkRemoved(ctx context.Context, userID int64, channelID int, removedAt time.Time) error {
+	query := `update access_warning set removed_at = $1 where user_id = $2 and channel_id = $3`
+
+	_, err := a.Pool.Exec(ctx, query, removedAt, userID, channelID)
+	return err
+}
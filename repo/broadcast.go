@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/postgres"
+	"time"
+)
+
+type BroadcastRepo interface {
+	Create(ctx context.Context, broadcast *model.Broadcast) error
+
+	GetByID(ctx context.Context, id int) (*model.Broadcast, error)
+	// GetUnfinished returns pending and running broadcasts, oldest first.
+	GetUnfinished(ctx context.Context) ([]model.Broadcast, error)
+
+	// Start marks the broadcast running, total and startedAt are only stored on the first start.
+	Start(ctx context.Context, id int, total int, startedAt time.Time) error
+	// UpdateProgress stores the counters and LastUserID.
+	UpdateProgress(ctx context.Context, broadcast *model.Broadcast) error
+	Finish(ctx context.Context, id int, status model.BroadcastStatus, finishedAt time.Time) error
+	// Cancel stops an unfinished broadcast, it reports whether the broadcast was still unfinished.
+	Cancel(ctx context.Context, id int) (bool, error)
+}
+
+type broadcastRepo struct {
+	*postgres.Postgres
+}
+
+func NewBroadcastRepo(pg *postgres.Postgres) BroadcastRepo {
+	return &broadcastRepo{
+		pg,
+	}
+}
+
+const broadcastColumns = `id, message_id, status, chat_id, progress_message_id, total, delivered, blocked, failed,
+	last_user_id, created_at, started_at, finished_at`
+
+func (b *broadcastRepo) collectRow(row pgx.Row) (*model.Broadcast, error) {
+	var broadcast model.Broadcast
+	err := row.Scan(&broadcast.ID, &broadcast.MessageID, &broadcast.Status, &broadcast.ChatID, &broadcast.ProgressMessageID,
+		&broadcast.Total, &broadcast.Delivered, &broadcast.Blocked, &broadcast.Failed,
+		&broadcast.LastUserID, &broadcast.CreatedAt, &broadcast.StartedAt, &broadcast.FinishedAt)
+
+	return &broadcast, err
+}
+
+func (b *broadcastRepo) collectRows(rows pgx.Rows) ([]model.Broadcast, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Broadcast, error) {
+		broadcast, err := b.collectRow(row)
+		return *broadcast, err
+	})
+}
+
+func (b *broadcastRepo) Create(ctx context.Context, broadcast *model.Broadcast) error {
+	query := `insert into broadcast (message_id, status, chat_id, progress_message_id, created_at)
+		values ($1,$2,$3,$4,$5) returning id`
+
+	if broadcast.Status == "" {
+		broadcast.Status = model.BroadcastPending
+	}
+	if broadcast.CreatedAt.IsZero() {
+		broadcast.CreatedAt = time.Now()
+	}
+
+	return b.Pool.QueryRow(ctx, query, broadcast.MessageID,
+		broadcast.Status,
+		broadcast.ChatID,
+		broadcast.ProgressMessageID,
+		broadcast.CreatedAt,
+	).Scan(&broadcast.ID)
+}
+
+func (b *broadcastRepo) GetByID(ctx context.Context, id int) (*model.Broadcast, error) {
+	query := `select ` + broadcastColumns + ` from broadcast where id = $1`
+
+	return b.collectRow(b.Pool.QueryRow(ctx, query, id))
+}
+
+func (b *broadcastRepo) GetUnfinished(ctx context.Context) ([]model.Broadcast, error) {
+	query := `select ` + broadcastColumns + ` from broadcast where status in ('pending', 'running') order by id`
+
+	rows, err := b.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return b.collectRows(rows)
+}
+
+func (b *broadcastRepo) Start(ctx context.Context, id int, total int, startedAt time.Time) error {
+	query := `update broadcast set status = 'running', total = case when started_at is null then $1 else total end,
+		started_at = coalesce(started_at, $2)
+		where id = $3 and status in ('pending', 'running')`
+
+	_, err := b.Pool.Exec(ctx, query, total, startedAt, id)
+	return err
+}
+
+func (b *broadcastRepo) UpdateProgress(ctx context.Context, broadcast *model.Broadcast) error {
+	query := `update broadcast set delivered = $1, blocked = $2, failed = $3, last_user_id = $4 where id = $5`
+
+	_, err := b.Pool.Exec(ctx, query, broadcast.Delivered, broadcast.Blocked, broadcast.Failed, broadcast.LastUserID,
+		broadcast.ID)
+	return err
+}
+
+func (b *broadcastRepo) Finish(ctx context.Context, id int, status model.BroadcastStatus, finishedAt time.Time) error {
+	query := `update broadcast set status = $1, finished_at = $2 where id = $3`
+
+	_, err := b.Pool.Exec(ctx, query, status, finishedAt, id)
+	return err
+}
+
+func (b *broadcastRepo) Cancel(ctx context.Context, id int) (bool, error) {
+	query := `update broadcast set status = 'cancelled' where id = $1 and status in ('pending', 'running')`
+
+	tag, err := b.Pool.Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,191 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/postgres"
+	"time"
+)
+
+type CampaignRepo interface {
+	// GetByID returns the campaign with its target and required channels.
+	// model.DefaultCampaignID returns the default campaign built from channel statuses.
+	GetByID(ctx context.Context, id int) (*model.Campaign, error)
+	GetBySlug(ctx context.Context, slug string) (*model.Campaign, error)
+	GetByTargetChannel(ctx context.Context, channelID int) ([]model.Campaign, error)
+	GetAll(ctx context.Context) ([]model.Campaign, error)
+
+	Create(ctx context.Context, campaign *model.Campaign) error
+
+	DeleteByID(ctx context.Context, id int) error
+
+	AddChannel(ctx context.Context, campaignID int, channelID int) error
+	RemoveChannel(ctx context.Context, campaignID int, channelID int) error
+}
+
+type campaignRepo struct {
+	*postgres.Postgres
+}
+
+func NewCampaignRepo(pg *postgres.Postgres) CampaignRepo {
+	return &campaignRepo{
+		pg,
+	}
+}
+
+const campaignColumns = `id, name, slug, target_channel_id, created_at`
+
+func (c *campaignRepo) collectRow(row pgx.Row) (*model.Campaign, error) {
+	var campaign model.Campaign
+	err := row.Scan(&campaign.ID, &campaign.Name, &campaign.Slug, &campaign.TargetChannelID, &campaign.CreatedAt)
+
+	return &campaign, err
+}
+
+func (c *campaignRepo) collectRows(rows pgx.Rows) ([]model.Campaign, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Campaign, error) {
+		campaign, err := c.collectRow(row)
+		return *campaign, err
+	})
+}
+
+func (c *campaignRepo) collectChannels(rows pgx.Rows) ([]model.Channel, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Channel, error) {
+		channel, err := scanChannel(row)
+		return *channel, err
+	})
+}
+
+func (c *campaignRepo) GetByID(ctx context.Context, id int) (*model.Campaign, error) {
+	if id == model.DefaultCampaignID {
+		return c.getDefault(ctx)
+	}
+
+	query := `select ` + campaignColumns + ` from campaign where id = $1`
+
+	campaign, err := c.collectRow(c.Pool.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, err
+	}
+	return campaign, c.fill(ctx, campaign)
+}
+
+func (c *campaignRepo) GetBySlug(ctx context.Context, slug string) (*model.Campaign, error) {
+	query := `select ` + campaignColumns + ` from campaign where slug = $1`
+
+	campaign, err := c.collectRow(c.Pool.QueryRow(ctx, query, slug))
+	if err != nil {
+		return nil, err
+	}
+	return campaign, c.fill(ctx, campaign)
+}
+
+func (c *campaignRepo) GetByTargetChannel(ctx context.Context, channelID int) ([]model.Campaign, error) {
+	query := `select ` + campaignColumns + ` from campaign where target_channel_id = $1 order by id`
+
+	rows, err := c.Pool.Query(ctx, query, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return c.collectRows(rows)
+}
+
+func (c *campaignRepo) GetAll(ctx context.Context) ([]model.Campaign, error) {
+	query := `select ` + campaignColumns + ` from campaign order by id`
+
+	rows, err := c.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return c.collectRows(rows)
+}
+
+func (c *campaignRepo) Create(ctx context.Context, campaign *model.Campaign) error {
+	query := `insert into campaign (name, slug, target_channel_id, created_at) values ($1,$2,$3,$4) returning id`
+
+	if campaign.CreatedAt.IsZero() {
+		campaign.CreatedAt = time.Now()
+	}
+
+	return c.Pool.QueryRow(ctx, query, campaign.Name,
+		campaign.Slug,
+		campaign.TargetChannelID,
+		campaign.CreatedAt,
+	).Scan(&campaign.ID)
+}
+
+func (c *campaignRepo) DeleteByID(ctx context.Context, id int) error {
+	query := `delete from campaign where id = $1`
+
+	_, err := c.Pool.Exec(ctx, query, id)
+	return err
+}
+
+func (c *campaignRepo) AddChannel(ctx context.Context, campaignID int, channelID int) error {
+	query := `insert into campaign_channel (campaign_id, channel_id) values ($1,$2) on conflict do nothing`
+
+	_, err := c.Pool.Exec(ctx, query, campaignID, channelID)
+	return err
+}
+
+func (c *campaignRepo) RemoveChannel(ctx context.Context, campaignID int, channelID int) error {
+	query := `delete from campaign_channel where campaign_id = $1 and channel_id = $2`
+
+	_, err := c.Pool.Exec(ctx, query, campaignID, channelID)
+	return err
+}
+
+// fill loads the target and required channels of campaign.
+func (c *campaignRepo) fill(ctx context.Context, campaign *model.Campaign) error {
+	target, err := c.collectChannelRow(ctx, `select `+channelColumns+` from channel c where c.id = $1`, campaign.TargetChannelID)
+	if err != nil {
+		return err
+	}
+	campaign.Target = target
+
+	query := `select ` + channelColumns + ` from channel c
+		join campaign_channel cc on cc.channel_id = c.id
+		where cc.campaign_id = $1 order by ` + channelOrder
+
+	rows, err := c.Pool.Query(ctx, query, campaign.ID)
+	if err != nil {
+		return err
+	}
+	campaign.Channels, err = c.collectChannels(rows)
+	return err
+}
+
+func (c *campaignRepo) getDefault(ctx context.Context) (*model.Campaign, error) {
+	campaign := &model.Campaign{
+		ID:   model.DefaultCampaignID,
+		Name: "Основная",
+	}
+
+	target, err := c.collectChannelRow(ctx, `select `+channelColumns+` from channel c where c.channel_status = 'main' limit 1`)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		campaign.Target = target
+		campaign.TargetChannelID = target.ID
+	}
+
+	// target channels of the other campaigns are private, their links must not reach default users
+	query := `select ` + channelColumns + ` from channel c
+		where c.channel_status = 'secondary'
+			and not exists (select 1 from campaign where target_channel_id = c.id)
+		order by ` + channelOrder
+
+	rows, err := c.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	campaign.Channels, err = c.collectChannels(rows)
+	return campaign, err
+}
+
+func (c *campaignRepo) collectChannelRow(ctx context.Context, query string, args ...any) (*model.Channel, error) {
+	return scanChannel(c.Pool.QueryRow(ctx, query, args...))
+}
@@ -20,6 +20,10 @@ type ChannelRepo interface {
 	DeleteByName(ctx context.Context, name string) error
 
 	UpdateStatus(ctx context.Context, status model.Status, id int) error
+	UpdateRules(ctx context.Context, channel *model.Channel) error
+	UpdateName(ctx context.Context, name string, id int) error
+	UpdateURL(ctx context.Context, url string, id int) error
+	UpdateSortOrder(ctx context.Context, ids []int) error
 
 	IsExistMainChannel(ctx context.Context) (bool, int, error)
 }
@@ -34,9 +38,19 @@ func NewChannelRepo(pg *postgres.Postgres) ChannelRepo {
 	}
 }
 
+const channelColumns = `c.id, c.channel_telegram_id, c.name, c.url, c.channel_status,
+	c.is_required, c.allow_restricted, c.starts_at, c.ends_at, c.sort_order`
+
+const channelOrder = `c.sort_order, c.id`
+
 func (c *channelRepo) collectRow(row pgx.Row) (*model.Channel, error) {
+	return scanChannel(row)
+}
+
+func scanChannel(row pgx.Row) (*model.Channel, error) {
 	var channel model.Channel
-	err := row.Scan(&channel.ID, &channel.ChannelTelegramId, &channel.Name, &channel.URL, &channel.ChannelStatus)
+	err := row.Scan(&channel.ID, &channel.ChannelTelegramId, &channel.Name, &channel.URL, &channel.ChannelStatus,
+		&channel.IsRequired, &channel.AllowRestricted, &channel.StartsAt, &channel.EndsAt, &channel.SortOrder)
 	//if errors.Is(err, pgx.ErrNoRows) {
 	//	return nil, boterror.ErrNoRows
 	//}
@@ -58,21 +72,21 @@ func (c *channelRepo) collectRows(rows pgx.Rows) ([]model.Channel, error) {
 }
 
 func (c *channelRepo) GetByID(ctx context.Context, id int) (*model.Channel, error) {
-	q := `SELECT id,channel_telegram_id, name, url, channel_status FROM channel WHERE id = $1`
+	q := `SELECT ` + channelColumns + ` FROM channel c WHERE c.id = $1`
 
 	row := c.Pool.QueryRow(ctx, q, id)
 	return c.collectRow(row)
 }
 
 func (c *channelRepo) GetByName(ctx context.Context, name string) (*model.Channel, error) {
-	q := `SELECT id,channel_telegram_id, name, url, channel_status FROM channel WHERE name = $1`
+	q := `SELECT ` + channelColumns + ` FROM channel c WHERE c.name = $1`
 
 	row := c.Pool.QueryRow(ctx, q, name)
 	return c.collectRow(row)
 }
 
 func (c *channelRepo) GetByStatus(ctx context.Context, status model.Status) ([]model.Channel, error) {
-	q := `SELECT id,channel_telegram_id, name, url, channel_status FROM channel WHERE channel_status = $1`
+	q := `SELECT ` + channelColumns + ` FROM channel c WHERE c.channel_status = $1 ORDER BY ` + channelOrder
 
 	rows, err := c.Pool.Query(ctx, q, status)
 	if err != nil {
@@ -82,14 +96,14 @@ func (c *channelRepo) GetByStatus(ctx context.Context, status model.Status) ([]m
 }
 
 func (c *channelRepo) GetByChannelTelegramID(ctx context.Context, channelTelegramID int64) (*model.Channel, error) {
-	q := `SELECT id,channel_telegram_id, name, url, channel_status FROM channel WHERE channel_telegram_id = $1`
+	q := `SELECT ` + channelColumns + ` FROM channel c WHERE c.channel_telegram_id = $1`
 
 	row := c.Pool.QueryRow(ctx, q, channelTelegramID)
 	return c.collectRow(row)
 }
 
 func (c *channelRepo) GetAll(ctx context.Context) ([]model.Channel, error) {
-	q := `SELECT id, name, url, channel_status FROM channel`
+	q := `SELECT ` + channelColumns + ` FROM channel c ORDER BY ` + channelOrder
 
 	rows, err := c.Pool.Query(ctx, q)
 	if err != nil {
@@ -131,8 +145,47 @@ func (c *channelRepo) UpdateStatus(ctx context.Context, status model.Status, id
 	return err
 }
 
+func (c *channelRepo) UpdateRules(ctx context.Context, channel *model.Channel) error {
+	q := `update channel set is_required = $1, allow_restricted = $2, starts_at = $3, ends_at = $4, sort_order = $5
+		where id = $6`
+
+	_, err := c.Pool.Exec(ctx, q, channel.IsRequired,
+		channel.AllowRestricted,
+		channel.StartsAt,
+		channel.EndsAt,
+		channel.SortOrder,
+		channel.ID,
+	)
+	return err
+}
+
+func (c *channelRepo) UpdateName(ctx context.Context, name string, id int) error {
+	q := `update channel set name = $1 where id = $2`
+
+	_, err := c.Pool.Exec(ctx, q, name, id)
+	return err
+}
+
+func (c *channelRepo) UpdateURL(ctx context.Context, url string, id int) error {
+	q := `update channel set url = $1 where id = $2`
+
+	_, err := c.Pool.Exec(ctx, q, url, id)
+	return err
+}
+
+// UpdateSortOrder numbers the channels in the order of ids, starting from 1.
+func (c *channelRepo) UpdateSortOrder(ctx context.Context, ids []int) error {
+	q := `update channel c set sort_order = o.position
+		from unnest($1::int[]) with ordinality as o(id, position)
+		where c.id = o.id`
+
+	_, err := c.Pool.Exec(ctx, q, ids)
+	return err
+}
+
 func (c *channelRepo) IsExistMainChannel(ctx context.Context) (bool, int, error) {
-	q := `SELECT EXISTS (SELECT id FROM channel WHERE channel_status = 'main') AS exists_main, id FROM channel`
+	q := `SELECT EXISTS (SELECT id FROM channel WHERE channel_status = 'main') AS exists_main,
+		coalesce((SELECT id FROM channel WHERE channel_status = 'main' LIMIT 1), 0)`
 
 	var (
 		isExist bool
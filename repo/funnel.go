@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"context"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/postgres"
+	"time"
+)
+
+type FunnelRepo interface {
+	// Create stores the event at createdAt, the bot's clock, so the stats periods counted from it match.
+	Create(ctx context.Context, userID int64, event model.FunnelEvent, campaignID int, createdAt time.Time) error
+
+	// GetStats counts the users who reached every step since, each user once per step.
+	GetStats(ctx context.Context, since time.Time) (*model.FunnelStats, error)
+}
+
+type funnelRepo struct {
+	*postgres.Postgres
+}
+
+func NewFunnelRepo(pg *postgres.Postgres) FunnelRepo {
+	return &funnelRepo{
+		pg,
+	}
+}
+
+func (f *funnelRepo) Create(ctx context.Context, userID int64, event model.FunnelEvent, campaignID int, createdAt time.Time) error {
+	query := `insert into funnel_event (user_id, event, campaign_id, created_at) values ($1,$2,nullif($3, 0),$4)`
+
+	_, err := f.Pool.Exec(ctx, query, userID, event, campaignID, createdAt)
+	return err
+}
+
+func (f *funnelRepo) GetStats(ctx context.Context, since time.Time) (*model.FunnelStats, error) {
+	query := `select count(distinct user_id) filter (where event = $2),
+			count(distinct user_id) filter (where event = $3),
+			count(distinct user_id) filter (where event = $4),
+			count(distinct user_id) filter (where event = $5)
+		from funnel_event
+		where created_at >= $1`
+
+	var stats model.FunnelStats
+	err := f.Pool.QueryRow(ctx, query, since,
+		model.FunnelStart,
+		model.FunnelSecondStep,
+		model.FunnelReadyFailed,
+		model.FunnelLinkIssued,
+	).Scan(&stats.Start, &stats.SecondStep, &stats.ReadyFailed, &stats.LinkIssued)
+
+	return &stats, err
+}
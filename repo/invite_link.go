@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/postgres"
+	"time"
+)
+
+type InviteLinkRepo interface {
+	Create(ctx context.Context, link *model.InviteLink) error
+
+	// GetActive returns the link issued to the user that is not used, revoked or expired yet.
+	GetActive(ctx context.Context, userID int64, channelID int, createsJoinRequest bool) (*model.InviteLink, error)
+	GetByLink(ctx context.Context, link string) (*model.InviteLink, error)
+	// GetUsed returns the latest used link of every user in every channel they joined through a link.
+	GetUsed(ctx context.Context) ([]model.InviteLink, error)
+	// GetExpiredUnused returns links that expired before now and were neither used nor revoked.
+	GetExpiredUnused(ctx context.Context, now time.Time) ([]model.InviteLink, error)
+
+	MarkUsed(ctx context.Context, link string, usedAt time.Time) error
+	MarkRevoked(ctx context.Context, id int, revokedAt time.Time) error
+}
+
+type inviteLinkRepo struct {
+	*postgres.Postgres
+}
+
+func NewInviteLinkRepo(pg *postgres.Postgres) InviteLinkRepo {
+	return &inviteLinkRepo{
+		pg,
+	}
+}
+
+const inviteLinkColumns = `l.id, l.user_id, l.channel_id, c.channel_telegram_id, coalesce(l.campaign_id, 0), l.invite_link, l.creates_join_request,
+	l.created_at, l.expires_at, l.used_at, l.revoked_at`
+
+func (i *inviteLinkRepo) collectRow(row pgx.Row) (*model.InviteLink, error) {
+	var link model.InviteLink
+	err := row.Scan(&link.ID, &link.UserID, &link.ChannelID, &link.ChannelTelegramID, &link.CampaignID, &link.InviteLink, &link.CreatesJoinRequest,
+		&link.CreatedAt, &link.ExpiresAt, &link.UsedAt, &link.RevokedAt)
+
+	return &link, err
+}
+
+func (i *inviteLinkRepo) collectRows(rows pgx.Rows) ([]model.InviteLink, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.InviteLink, error) {
+		link, err := i.collectRow(row)
+		return *link, err
+	})
+}
+
+func (i *inviteLinkRepo) Create(ctx context.Context, link *model.InviteLink) error {
+	query := `insert into invite_link (user_id, channel_id, campaign_id, invite_link, creates_join_request, created_at, expires_at)
+		values ($1,$2,nullif($3, 0),$4,$5,$6,$7) returning id`
+
+	return i.Pool.QueryRow(ctx, query, link.UserID,
+		link.ChannelID,
+		link.CampaignID,
+		link.InviteLink,
+		link.CreatesJoinRequest,
+		link.CreatedAt,
+		link.ExpiresAt,
+	).Scan(&link.ID)
+}
+
+func (i *inviteLinkRepo) GetActive(ctx context.Context, userID int64, channelID int, createsJoinRequest bool) (*model.InviteLink, error) {
+	query := `select ` + inviteLinkColumns + ` from invite_link l join channel c on c.id = l.channel_id
+		where l.user_id = $1 and l.channel_id = $2 and l.creates_join_request = $3
+		and l.used_at is null and l.revoked_at is null and l.expires_at > $4
+		order by l.expires_at desc limit 1`
+
+	row := i.Pool.QueryRow(ctx, query, userID, channelID, createsJoinRequest, time.Now())
+	return i.collectRow(row)
+}
+
+func (i *inviteLinkRepo) GetByLink(ctx context.Context, link string) (*model.InviteLink, error) {
+	query := `select ` + inviteLinkColumns + ` from invite_link l join channel c on c.id = l.channel_id
+		where l.invite_link = $1`
+
+	row := i.Pool.QueryRow(ctx, query, link)
+	return i.collectRow(row)
+}
+
+func (i *inviteLinkRepo) GetUsed(ctx context.Context) ([]model.InviteLink, error) {
+	query := `select distinct on (l.user_id, l.channel_id) ` + inviteLinkColumns + ` from invite_link l join channel c on c.id = l.channel_id
+		where l.used_at is not null
+		order by l.user_id, l.channel_id, l.used_at desc`
+
+	rows, err := i.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return i.collectRows(rows)
+}
+
+func (i *inviteLinkRepo) GetExpiredUnused(ctx context.Context, now time.Time) ([]model.InviteLink, error) {
+	query := `select ` + inviteLinkColumns + ` from invite_link l join channel c on c.id = l.channel_id
+		where l.used_at is null and l.revoked_at is null and l.expires_at <= $1`
+
+	rows, err := i.Pool.Query(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	return i.collectRows(rows)
+}
+
+func (i *inviteLinkRepo) MarkUsed(ctx context.Context, link string, usedAt time.Time) error {
+	query := `update invite_link set used_at = $1 where invite_link = $2 and used_at is null`
+
+	_, err := i.Pool.Exec(ctx, query, usedAt, link)
+	return err
+}
+
+func (i *inviteLinkRepo) MarkRevoked(ctx context.Context, id int, revokedAt time.Time) error {
+	query := `update invite_link set revoked_at = $1 where id = $2`
+
+	_, err := i.Pool.Exec(ctx, query, revokedAt, id)
+	return err
+}
@@ -9,6 +9,7 @@ import (
 
 type MessageRepo interface {
 	GetByID(ctx context.Context, id int) (*model.Message, error)
+	GetByKey(ctx context.Context, key string) (*model.Message, error)
 
 	Create(ctx context.Context, message *model.Message) error
 
@@ -31,7 +32,7 @@ func NewMessageRepo(pg *postgres.Postgres) MessageRepo {
 
 func (c *messageRepo) collectRow(row pgx.Row) (*model.Message, error) {
 	var channel model.Message
-	err := row.Scan(&channel.ID, &channel.Message, &channel.FileID, &channel.ButtonText, &channel.ButtonUrl)
+	err := row.Scan(&channel.ID, &channel.Key, &channel.Message, &channel.FileID, &channel.FileType, &channel.ButtonUrl, &channel.ButtonText)
 
 	return &channel, err
 }
@@ -44,21 +45,29 @@ func (c *messageRepo) collectRows(rows pgx.Rows) ([]model.Message, error) {
 }
 
 func (m *messageRepo) GetByID(ctx context.Context, id int) (*model.Message, error) {
-	q := `select id,message,file_id,button_url,button_text from message where id = $1`
+	q := `select id,key,message,file_id,file_type,button_url,button_text from message where id = $1`
 
 	row := m.Pool.QueryRow(ctx, q, id)
 	return m.collectRow(row)
 }
 
+func (m *messageRepo) GetByKey(ctx context.Context, key string) (*model.Message, error) {
+	q := `select id,key,message,file_id,file_type,button_url,button_text from message where key = $1`
+
+	row := m.Pool.QueryRow(ctx, q, key)
+	return m.collectRow(row)
+}
+
 func (m *messageRepo) Create(ctx context.Context, message *model.Message) error {
-	q := `insert into message (message,file_id,button_url,button_text) values ($1,$2,$3,$4)`
+	q := `insert into message (key,message,file_id,file_type,button_url,button_text) values ($1,$2,$3,$4,$5,$6) returning id`
 
-	_, err := m.Pool.Exec(ctx, q, message.Message,
+	return m.Pool.QueryRow(ctx, q, message.Key,
+		message.Message,
 		message.FileID,
+		message.FileType,
 		message.ButtonUrl,
 		message.ButtonText,
-	)
-	return err
+	).Scan(&message.ID)
 }
 
 func (m *messageRepo) DeleteByID(ctx context.Context, id int) error {
@@ -69,21 +78,21 @@ func (m *messageRepo) DeleteByID(ctx context.Context, id int) error {
 }
 
 func (m *messageRepo) UpdateTextByID(ctx context.Context, text *string, id int) error {
-	query := `update channel set notification_text = $1 where id = $2`
+	query := `update message set message = $1 where id = $2`
 
 	_, err := m.Pool.Exec(ctx, query, text, id)
 	return err
 }
 
 func (m *messageRepo) UpdateFileByID(ctx context.Context, fileID *string, fileType *string, id int) error {
-	query := `update channek set file_id = $1, file_type = $2 where id = $3`
+	query := `update message set file_id = $1, file_type = $2 where id = $3`
 
 	_, err := m.Pool.Exec(ctx, query, fileID, fileType, id)
 	return err
 }
 
 func (m *messageRepo) UpdateButtonByID(ctx context.Context, buttonText *string, buttonURL *string, id int) error {
-	query := `update channel set button_url = $1, button_text = $2 where id = $3`
+	query := `update message set button_url = $1, button_text = $2 where id = $3`
 
 	_, err := m.Pool.Exec(ctx, query, buttonURL, buttonText, id)
 	return err
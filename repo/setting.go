@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"strconv"
+	"subscriber-check-bot/pkg/postgres"
+)
+
+// SettingCaptchaEnabled switches the human verification challenge before the invite link.
+const SettingCaptchaEnabled = "captcha_enabled"
+
+// SettingRepo keeps settings admins change at runtime.
+type SettingRepo interface {
+	// GetBool returns the setting or def if it was never set.
+	GetBool(ctx context.Context, key string, def bool) (bool, error)
+	SetBool(ctx context.Context, key string, value bool) error
+}
+
+type settingRepo struct {
+	*postgres.Postgres
+}
+
+func NewSettingRepo(pg *postgres.Postgres) SettingRepo {
+	return &settingRepo{
+		pg,
+	}
+}
+
+func (s *settingRepo) GetBool(ctx context.Context, key string, def bool) (bool, error) {
+	query := `select value from setting where key = $1`
+	var value string
+
+	err := s.Pool.QueryRow(ctx, query, key).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return def, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return strconv.ParseBool(value)
+}
+
+func (s *settingRepo) SetBool(ctx context.Context, key string, value bool) error {
+	query := `insert into setting (key, value, updated_at) values ($1,$2,now())
+		on conflict (key) do update set value = excluded.value, updated_at = excluded.updated_at`
+
+	_, err := s.Pool.Exec(ctx, query, key, strconv.FormatBool(value))
+	return err
+}
@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/pkg/postgres"
+	"time"
+)
+
+type UpdateRepo interface {
+	GetOffset(ctx context.Context) (int, error)
+	SaveOffset(ctx context.Context, offset int) error
+
+	// MarkProcessed records updateID and reports whether it was seen for the first time.
+	MarkProcessed(ctx context.Context, updateID int) (bool, error)
+	DeleteProcessedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type updateRepo struct {
+	*postgres.Postgres
+}
+
+func NewUpdateRepo(pg *postgres.Postgres) UpdateRepo {
+	return &updateRepo{
+		pg,
+	}
+}
+
+func (u *updateRepo) GetOffset(ctx context.Context) (int, error) {
+	query := `select update_offset from update_offset where id = 1`
+	var offset int
+
+	err := u.Pool.QueryRow(ctx, query).Scan(&offset)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return offset, err
+}
+
+func (u *updateRepo) SaveOffset(ctx context.Context, offset int) error {
+	query := `insert into update_offset (id, update_offset, updated_at) values (1, $1, $2)
+		on conflict (id) do update set update_offset = excluded.update_offset, updated_at = excluded.updated_at
+		where update_offset.update_offset < excluded.update_offset`
+
+	_, err := u.Pool.Exec(ctx, query, offset, time.Now())
+	return err
+}
+
+func (u *updateRepo) MarkProcessed(ctx context.Context, updateID int) (bool, error) {
+	query := `insert into processed_update (update_id, processed_at) values ($1, $2) on conflict (update_id) do nothing`
+
+	tag, err := u.Pool.Exec(ctx, query, updateID, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (u *updateRepo) DeleteProcessedBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `delete from processed_update where processed_at < $1`
+
+	tag, err := u.Pool.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -5,17 +5,35 @@ import (
 	"github.com/jackc/pgx/v5"
 	"subscriber-check-bot/model"
 	"subscriber-check-bot/pkg/postgres"
+	"time"
 )
 
 type UserRepo interface {
 	CreateUser(ctx context.Context, user *model.User) error
 	GetAllUsers(ctx context.Context) ([]model.User, error)
+	CountUsers(ctx context.Context) (int, error)
+	// GetUsersAfter returns the users with an ID greater than id, ordered by ID.
+	GetUsersAfter(ctx context.Context, id int64) ([]model.User, error)
 	GetUserByID(ctx context.Context, id int64) (*model.User, error)
-	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	// GetUsersByUsername matches the username ignoring case, Telegram usernames are case insensitive
+	// but a stale row may still hold the username of another user.
+	GetUsersByUsername(ctx context.Context, username string) ([]model.User, error)
 	UpdateRoleByUsername(ctx context.Context, role string, username string) error
+	// UpdateRoleByID reports whether the role was changed, it isn't when the user is not found or is the last superAdmin.
+	UpdateRoleByID(ctx context.Context, role string, id int64) (bool, error)
 	IsUserExistByUsernameTg(ctx context.Context, usernameTg string) (bool, error)
 	GetAllAdmin(ctx context.Context) ([]model.User, error)
 	IsUserExistByUserID(ctx context.Context, userID int64) (bool, error)
+	UpdateCampaign(ctx context.Context, userID int64, campaignID int) error
+
+	// ConfirmReferral makes the referral of the user count, it is a no-op without a referrer or when already confirmed.
+	ConfirmReferral(ctx context.Context, userID int64) error
+	CountReferrals(ctx context.Context, referrerID int64) (int, error)
+	GetTopReferrers(ctx context.Context, limit int) ([]model.Referrer, error)
+
+	// CountNewByDay counts the users registered on every day from since to until, days without users included.
+	// The days are those of the bot's clock, which the registration time is stored in.
+	CountNewByDay(ctx context.Context, since, until time.Time) ([]model.DailyCount, error)
 }
 
 type userRepo struct {
@@ -28,9 +46,12 @@ func NewUserRepo(pg *postgres.Postgres) UserRepo {
 	}
 }
 
+const userColumns = `id, tg_username, created_at, user_role, campaign_id, referrer_id, referral_confirmed_at`
+
 func (u *userRepo) collectRow(row pgx.Row) (*model.User, error) {
 	var user model.User
-	err := row.Scan(&user.ID, &user.UsernameTg, &user.CreatedAt, &user.Role)
+	err := row.Scan(&user.ID, &user.UsernameTg, &user.CreatedAt, &user.Role, &user.CampaignID,
+		&user.ReferrerID, &user.ReferralConfirmedAt)
 
 	return &user, err
 }
@@ -43,14 +64,14 @@ func (u *userRepo) collectRows(rows pgx.Rows) ([]model.User, error) {
 }
 
 func (u *userRepo) CreateUser(ctx context.Context, user *model.User) error {
-	query := `insert into "user" (id,tg_username,created_at,user_role) values ($1,$2,$3,$4)`
+	query := `insert into "user" (id,tg_username,created_at,user_role,referrer_id) values ($1,$2,$3,$4,$5)`
 
-	_, err := u.Pool.Exec(ctx, query, user.ID, user.UsernameTg, user.CreatedAt, user.Role)
+	_, err := u.Pool.Exec(ctx, query, user.ID, user.UsernameTg, user.CreatedAt, user.Role, user.ReferrerID)
 	return err
 }
 
 func (u *userRepo) GetAllUsers(ctx context.Context) ([]model.User, error) {
-	query := `select * from "user"`
+	query := `select ` + userColumns + ` from "user" order by id`
 
 	rows, err := u.Pool.Query(ctx, query)
 	if err != nil {
@@ -59,18 +80,39 @@ func (u *userRepo) GetAllUsers(ctx context.Context) ([]model.User, error) {
 	return u.collectRows(rows)
 }
 
+func (u *userRepo) CountUsers(ctx context.Context) (int, error) {
+	query := `select count(*) from "user"`
+	var count int
+
+	err := u.Pool.QueryRow(ctx, query).Scan(&count)
+	return count, err
+}
+
+func (u *userRepo) GetUsersAfter(ctx context.Context, id int64) ([]model.User, error) {
+	query := `select ` + userColumns + ` from "user" where id > $1 order by id`
+
+	rows, err := u.Pool.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return u.collectRows(rows)
+}
+
 func (u *userRepo) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
-	query := `select * from "user" where id = $1`
+	query := `select ` + userColumns + ` from "user" where id = $1`
 
 	row := u.Pool.QueryRow(ctx, query, id)
 	return u.collectRow(row)
 }
 
-func (u *userRepo) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
-	query := `select * from "user" where tg_username = $1`
+func (u *userRepo) GetUsersByUsername(ctx context.Context, username string) ([]model.User, error) {
+	query := `select ` + userColumns + ` from "user" where lower(tg_username) = lower($1) order by id`
 
-	row := u.Pool.QueryRow(ctx, query, username)
-	return u.collectRow(row)
+	rows, err := u.Pool.Query(ctx, query, username)
+	if err != nil {
+		return nil, err
+	}
+	return u.collectRows(rows)
 }
 
 func (u *userRepo) UpdateRoleByUsername(ctx context.Context, role string, username string) error {
@@ -80,6 +122,18 @@ func (u *userRepo) UpdateRoleByUsername(ctx context.Context, role string, userna
 	return err
 }
 
+func (u *userRepo) UpdateRoleByID(ctx context.Context, role string, id int64) (bool, error) {
+	// the superAdmin rows are locked, so two superAdmins demoting each other can't both succeed
+	query := `update "user" set user_role = $1 where id = $2 and (user_role <> 'superAdmin' or
+		(select count(*) from (select id from "user" where user_role = 'superAdmin' for update) s) > 1)`
+
+	tag, err := u.Pool.Exec(ctx, query, role, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
 func (u *userRepo) IsUserExistByUsernameTg(ctx context.Context, usernameTg string) (bool, error) {
 	query := `select exists (select id from "user" where tg_username = $1)`
 	var isExist bool
@@ -90,7 +144,7 @@ func (u *userRepo) IsUserExistByUsernameTg(ctx context.Context, usernameTg strin
 }
 
 func (u *userRepo) GetAllAdmin(ctx context.Context) ([]model.User, error) {
-	query := `select * from "user" where user_role = 'admin' or user_role = 'superAdmin'`
+	query := `select ` + userColumns + ` from "user" where user_role = 'admin' or user_role = 'superAdmin'`
 
 	rows, err := u.Pool.Query(ctx, query)
 	if err != nil {
@@ -106,3 +160,68 @@ func (u *userRepo) IsUserExistByUserID(ctx context.Context, userID int64) (bool,
 	err := u.Pool.QueryRow(ctx, query, userID).Scan(&isExist)
 	return isExist, err
 }
+
+func (u *userRepo) UpdateCampaign(ctx context.Context, userID int64, campaignID int) error {
+	query := `update "user" set campaign_id = nullif($1, 0) where id = $2`
+
+	_, err := u.Pool.Exec(ctx, query, campaignID, userID)
+	return err
+}
+
+func (u *userRepo) ConfirmReferral(ctx context.Context, userID int64) error {
+	query := `update "user" set referral_confirmed_at = now()
+		where id = $1 and referrer_id is not null and referral_confirmed_at is null`
+
+	_, err := u.Pool.Exec(ctx, query, userID)
+	return err
+}
+
+func (u *userRepo) CountReferrals(ctx context.Context, referrerID int64) (int, error) {
+	query := `select count(*) from "user" where referrer_id = $1 and referral_confirmed_at is not null`
+	var count int
+
+	err := u.Pool.QueryRow(ctx, query, referrerID).Scan(&count)
+	return count, err
+}
+
+func (u *userRepo) GetTopReferrers(ctx context.Context, limit int) ([]model.Referrer, error) {
+	query := `select r.id, r.tg_username, r.created_at, r.user_role, r.campaign_id, r.referrer_id, r.referral_confirmed_at,
+			count(*) as referrals
+		from "user" u join "user" r on r.id = u.referrer_id
+		where u.referral_confirmed_at is not null
+		group by r.id
+		order by referrals desc, r.id
+		limit $1`
+
+	rows, err := u.Pool.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Referrer, error) {
+		var referrer model.Referrer
+		user := &referrer.User
+		err := row.Scan(&user.ID, &user.UsernameTg, &user.CreatedAt, &user.Role, &user.CampaignID,
+			&user.ReferrerID, &user.ReferralConfirmedAt, &referrer.Referrals)
+		return referrer, err
+	})
+}
+
+func (u *userRepo) CountNewByDay(ctx context.Context, since, until time.Time) ([]model.DailyCount, error) {
+	query := `select d.day, count(u.id)
+		from generate_series($1::date, $2::date, interval '1 day') as d(day)
+		left join "user" u on u.created_at::date = d.day
+		group by d.day
+		order by d.day`
+
+	rows, err := u.Pool.Query(ctx, query, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.DailyCount, error) {
+		var count model.DailyCount
+		err := row.Scan(&count.Day, &count.Count)
+		return count, err
+	})
+}
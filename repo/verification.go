@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"subscriber-check-bot/model"
+	"subscriber-check-bot/pkg/postgres"
+	"time"
+)
+
+type VerificationRepo interface {
+	// Create stores the verification with its channel outcomes.
+	Create(ctx context.Context, verification *model.Verification) error
+
+	// GetLatest returns the latest verification of the user with its channel outcomes.
+	GetLatest(ctx context.Context, userID int64) (*model.Verification, error)
+	// CountLatestPerUser counts users by the outcome of their latest verification.
+	CountLatestPerUser(ctx context.Context) (*model.VerificationCounts, error)
+	// GetFailuresByChannel counts failed required channels of verifications made since, most failed first.
+	GetFailuresByChannel(ctx context.Context, since time.Time) ([]model.ChannelFailures, error)
+}
+
+type verificationRepo struct {
+	*postgres.Postgres
+}
+
+func NewVerificationRepo(pg *postgres.Postgres) VerificationRepo {
+	return &verificationRepo{
+		pg,
+	}
+}
+
+const verificationColumns = `id, user_id, coalesce(campaign_id, 0), checked_at, passed, link_issued`
+
+func (v *verificationRepo) collectRow(row pgx.Row) (*model.Verification, error) {
+	var verification model.Verification
+	err := row.Scan(&verification.ID, &verification.UserID, &verification.CampaignID, &verification.CheckedAt,
+		&verification.Passed, &verification.LinkIssued)
+
+	return &verification, err
+}
+
+func (v *verificationRepo) collectRows(rows pgx.Rows) ([]model.Verification, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Verification, error) {
+		verification, err := v.collectRow(row)
+		return *verification, err
+	})
+}
+
+func (v *verificationRepo) Create(ctx context.Context, verification *model.Verification) error {
+	return pgx.BeginFunc(ctx, v.Pool, func(tx pgx.Tx) error {
+		query := `insert into verification (user_id, campaign_id, checked_at, passed, link_issued)
+			values ($1,nullif($2, 0),$3,$4,$5) returning id`
+
+		if err := tx.QueryRow(ctx, query, verification.UserID,
+			verification.CampaignID,
+			verification.CheckedAt,
+			verification.Passed,
+			verification.LinkIssued,
+		).Scan(&verification.ID); err != nil {
+			return err
+		}
+
+		rows := make([][]any, 0, len(verification.Channels))
+		for _, el := range verification.Channels {
+			rows = append(rows, []any{verification.ID, el.ChannelID, el.ChannelName, el.Status, el.Required})
+		}
+
+		_, err := tx.CopyFrom(ctx, pgx.Identifier{"verification_channel"},
+			[]string{"verification_id", "channel_id", "channel_name", "status", "required"},
+			pgx.CopyFromRows(rows),
+		)
+		return err
+	})
+}
+
+func (v *verificationRepo) GetLatest(ctx context.Context, userID int64) (*model.Verification, error) {
+	query := `select ` + verificationColumns + ` from verification where user_id = $1 order by checked_at desc limit 1`
+
+	verification, err := v.collectRow(v.Pool.QueryRow(ctx, query, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	query = `select channel_id, channel_name, status, required from verification_channel where verification_id = $1`
+
+	rows, err := v.Pool.Query(ctx, query, verification.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	verification.Channels, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.VerificationChannel, error) {
+		var channel model.VerificationChannel
+		err := row.Scan(&channel.ChannelID, &channel.ChannelName, &channel.Status, &channel.Required)
+		return channel, err
+	})
+	return verification, err
+}
+
+func (v *verificationRepo) CountLatestPerUser(ctx context.Context) (*model.VerificationCounts, error) {
+	query := `select count(*), count(*) filter (where passed), count(*) filter (where link_issued)
+		from (select distinct on (user_id) passed, link_issued from verification order by user_id, checked_at desc) latest`
+
+	var counts model.VerificationCounts
+	err := v.Pool.QueryRow(ctx, query).Scan(&counts.Users, &counts.Passed, &counts.LinkIssued)
+	if err != nil {
+		return nil, err
+	}
+	return &counts, nil
+}
+
+func (v *verificationRepo) GetFailuresByChannel(ctx context.Context, since time.Time) ([]model.ChannelFailures, error) {
+	query := `select vc.channel_id, c.name,
+			count(*) filter (where vc.status = 'not_member'),
+			count(*) filter (where vc.status = 'failed')
+		from verification_channel vc
+		join verification v on v.id = vc.verification_id
+		join channel c on c.id = vc.channel_id
+		where v.checked_at >= $1 and vc.required and vc.status <> 'member'
+		group by vc.channel_id, c.name
+		order by count(*) desc`
+
+	rows, err := v.Pool.Query(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.ChannelFailures, error) {
+		var failures model.ChannelFailures
+		err := row.Scan(&failures.ChannelID, &failures.ChannelName, &failures.NotMember, &failures.Failed)
+		return failures, err
+	})
+}